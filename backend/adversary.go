@@ -0,0 +1,441 @@
+package main
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"runtime"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Adversarial search: random-restart hill climbing over small workloads to
+// find one where an algorithm does as badly as possible, on its own or
+// against a baseline algorithm. The worst workload found comes back ready
+// to be replayed, for regression suites and teaching.
+
+// Bounds on a search: processes per workload, and simulations over all
+// restarts, the baseline's included
+const (
+	maxSearchProcesses   = 200
+	maxSearchEvaluations = 20000
+)
+
+// Defaults for a search, with times in time units
+const (
+	defaultSearchProcesses  = 8
+	defaultSearchArrival    = 20
+	defaultSearchBurst      = 10
+	defaultSearchPriority   = 10
+	defaultSearchRestarts   = 5
+	defaultSearchIterations = 300
+)
+
+// AdversaryRequest carries the options shared by every simulation, plus
+// what to search for. Processes, if given, are where the first restart
+// starts; the others start from random workloads of Count processes.
+type AdversaryRequest struct {
+	SimulationRequest
+
+	Target   AlgorithmConfig  `json:"target"`
+	Baseline *AlgorithmConfig `json:"baseline,omitempty"`
+
+	// Metric to make worse, one of the /compare ranking metrics. With a
+	// baseline, Gap is "ratio" (the default) or "difference" of the
+	// target's metric against the baseline's, oriented so that larger
+	// means the target did worse.
+	Objective string `json:"objective"`
+	Gap       string `json:"gap,omitempty"`
+
+	Count          int  `json:"count,omitempty"`
+	MaxArrivalTime *int `json:"maxArrivalTime,omitempty" time:"ticks"`
+	MaxBurstTime   int  `json:"maxBurstTime,omitempty" time:"ticks"`
+	MaxPriority    int  `json:"maxPriority,omitempty"`
+
+	Restarts   int    `json:"restarts,omitempty"`
+	Iterations int    `json:"iterations,omitempty"` // Per restart
+	Seed       *int64 `json:"seed,omitempty"`
+}
+
+type AdversaryResponse struct {
+	Seed      int64  `json:"seed"`
+	Objective string `json:"objective"`
+	Gap       string `json:"gap,omitempty"`
+
+	// The objective on the worst workload, and the metric for each
+	// algorithm on it
+	Score         float64  `json:"score"`
+	TargetValue   float64  `json:"targetValue"`
+	BaselineValue *float64 `json:"baselineValue,omitempty"`
+
+	// Best score each restart reached, and simulations run in all, of the
+	// target and the baseline
+	RestartScores []float64 `json:"restartScores"`
+	Evaluations   int       `json:"evaluations"`
+
+	Processes      []GeneratedProcess  `json:"processes"`
+	Result         SimulationResponse  `json:"result"`
+	BaselineResult *SimulationResponse `json:"baselineResult,omitempty"`
+}
+
+// Process keys a search can vary; other keys would be dropped from the
+// workload it returns
+var searchableProcessFields = map[string]bool{"id": true, "arrivalTime": true, "burstTime": true, "priority": true}
+
+// adversary is a validated search with its defaults filled in, in ticks
+type adversary struct {
+	req        AdversaryRequest
+	metric     compareMetric
+	scale      int64
+	maxArrival int
+	maxBurst   int
+	priorities bool
+
+	simulations atomic.Int64 // Run so far, over all restarts
+}
+
+// One evaluated workload
+type candidate struct {
+	procs         []Process
+	key           float64 // Larger is worse for the target
+	target        SimulationResponse
+	baseline      *SimulationResponse
+	score         float64
+	targetValue   float64
+	baselineValue float64
+}
+
+func handleAdversary(c *gin.Context) {
+	var req AdversaryRequest
+	scale, raw, ok := decodeBody(c, &req)
+	if !ok {
+		return
+	}
+	if req.Seed == nil {
+		seed := rand.Int64N(1 << 53)
+		req.Seed = &seed
+	}
+	a, errs := newAdversary(req, raw, scale)
+	if len(errs) > 0 {
+		rejectRequest(c, errs)
+		return
+	}
+
+	respond(c, a.search(), req.TimeResolution)
+}
+
+// Fill in the defaults of a search and check it, including the target and
+// baseline as simulations of the starting workload
+func newAdversary(req AdversaryRequest, raw map[string]interface{}, scale int64) (*adversary, []FieldError) {
+	var errs []FieldError
+	add := func(field, code, message string) {
+		errs = append(errs, fieldError(field, code, message))
+	}
+
+	a := &adversary{req: req, scale: scale}
+	if m := findCompareMetric(req.Objective); m != nil {
+		a.metric = *m
+	} else if req.Objective == "" {
+		add("objective", codeRequired, "Objective is required")
+	} else {
+		add("objective", codeUnknown, "Unknown objective")
+	}
+	switch {
+	case req.Baseline == nil && req.Gap != "":
+		add("gap", codeUnsupported, "A gap needs a baseline to compare against")
+	case req.Baseline != nil && req.Gap == "":
+		a.req.Gap = "ratio"
+	case req.Baseline != nil && req.Gap != "ratio" && req.Gap != "difference":
+		add("gap", codeUnknown, "Gap must be ratio or difference")
+	}
+
+	// Workload shape
+	if len(req.Processes) > 0 {
+		if req.Count != 0 && req.Count != len(req.Processes) {
+			add("count", codeOutOfRange, "Count must match the processes given")
+		}
+		a.req.Count = len(req.Processes)
+		rawProcs, _ := raw["processes"].([]interface{})
+		for i, rp := range rawProcs {
+			fields, _ := rp.(map[string]interface{})
+			keys := make([]string, 0, len(fields))
+			for key := range fields {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				if !searchableProcessFields[key] {
+					add(fmt.Sprintf("processes[%d].%s", i, key), codeUnsupported,
+						"A search only varies id, arrivalTime, burstTime and priority")
+				}
+			}
+		}
+	}
+	if a.req.Count == 0 {
+		a.req.Count = defaultSearchProcesses
+	}
+	if a.req.Count < 0 || a.req.Count > maxSearchProcesses {
+		add("count", codeOutOfRange, fmt.Sprintf("Count must be from 1 to %d", maxSearchProcesses))
+	}
+	a.maxArrival = defaultSearchArrival * int(scale)
+	if req.MaxArrivalTime != nil {
+		a.maxArrival = *req.MaxArrivalTime
+	}
+	a.maxBurst = req.MaxBurstTime
+	if a.maxBurst == 0 {
+		a.maxBurst = defaultSearchBurst * int(scale)
+	}
+	switch {
+	case a.maxArrival < 0:
+		add("maxArrivalTime", codeNegative, "Max arrival time cannot be negative")
+	case a.maxArrival > maxGeneratedTicks:
+		add("maxArrivalTime", codeOutOfRange, "Max arrival time cannot exceed 2^40 ticks")
+	}
+	switch {
+	case a.maxBurst < 0:
+		add("maxBurstTime", codeNotPositive, "Max burst time must be positive")
+	case a.maxBurst > maxGeneratedTicks:
+		add("maxBurstTime", codeOutOfRange, "Max burst time cannot exceed 2^40 ticks")
+	}
+	if a.req.MaxPriority == 0 {
+		a.req.MaxPriority = defaultSearchPriority
+	}
+	switch {
+	case a.req.MaxPriority < 0:
+		add("maxPriority", codeNotPositive, "Max priority must be positive")
+	case a.req.MaxPriority > maxGeneratedPriority:
+		add("maxPriority", codeOutOfRange, "Max priority cannot exceed 2^53")
+	}
+
+	// Search budget
+	if a.req.Restarts == 0 {
+		a.req.Restarts = defaultSearchRestarts
+	}
+	if a.req.Iterations == 0 {
+		a.req.Iterations = defaultSearchIterations
+	}
+	// Every workload is simulated once per algorithm
+	runs := 1
+	if req.Baseline != nil {
+		runs = 2
+	}
+	budget := fmt.Sprintf("Restarts times iterations cannot exceed %d", maxSearchEvaluations)
+	if runs > 1 {
+		budget = fmt.Sprintf("Restarts times iterations cannot exceed %d with a baseline", maxSearchEvaluations/runs)
+	}
+	switch {
+	case a.req.Restarts < 0:
+		add("restarts", codeNotPositive, "Restarts must be positive")
+	case a.req.Restarts > maxSearchEvaluations:
+		add("restarts", codeOutOfRange, budget)
+	}
+	switch {
+	case a.req.Iterations < 0:
+		add("iterations", codeNotPositive, "Iterations must be positive")
+	case a.req.Iterations > maxSearchEvaluations:
+		add("iterations", codeOutOfRange, budget)
+	}
+	// Both are at most maxSearchEvaluations here, so the product fits
+	if len(errs) == 0 && a.req.Restarts*a.req.Iterations*runs > maxSearchEvaluations {
+		add("iterations", codeOutOfRange, budget)
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	// The algorithms, checked on the workload the first restart starts from
+	a.priorities = isPriorityAlgorithm(req.Target.Algorithm) ||
+		req.Baseline != nil && isPriorityAlgorithm(req.Baseline.Algorithm)
+	base := req.SimulationRequest
+	base.Processes = a.start(0, a.rng(0))
+	errs = validateRun(base, req.Target, "target", raw)
+	if req.Baseline != nil {
+		seen := make(map[FieldError]bool)
+		for _, e := range errs {
+			seen[e] = true
+		}
+		for _, e := range validateRun(base, *req.Baseline, "baseline", raw) {
+			if !seen[e] {
+				errs = append(errs, e)
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	// Later workloads are not validated, so the longest one a step-by-step
+	// algorithm could be handed has to fit now
+	worst := base
+	worst.Processes = make([]Process, a.req.Count)
+	for i := range worst.Processes {
+		worst.Processes[i] = Process{ID: strconv.Itoa(i + 1), BurstTime: a.maxBurst}
+	}
+	configs := []AlgorithmConfig{req.Target}
+	if req.Baseline != nil {
+		configs = append(configs, *req.Baseline)
+	}
+	for _, cfg := range configs {
+		if steppedTicks(runRequest(worst, cfg)) > maxSteppedTicks {
+			return nil, []FieldError{fieldError("maxBurstTime", codeOutOfRange,
+				fmt.Sprintf("Count times max burst time cannot exceed %d ticks for %s", maxSteppedTicks, cfg.Algorithm))}
+		}
+	}
+	return a, nil
+}
+
+// Random source for one restart, so restarts can run concurrently and
+// still give the same result for the same seed
+func (a *adversary) rng(restart int) *rand.Rand {
+	seed := uint64(*a.req.Seed)
+	return rand.New(rand.NewPCG(seed, uint64(restart)))
+}
+
+// The workload a restart starts from: the given processes for the first,
+// a random one otherwise
+func (a *adversary) start(restart int, rng *rand.Rand) []Process {
+	if restart == 0 && len(a.req.Processes) > 0 {
+		return cloneProcesses(a.req.Processes)
+	}
+	procs := make([]Process, a.req.Count)
+	for i := range procs {
+		procs[i] = Process{
+			ID:          fmt.Sprintf("P%d", i+1),
+			ArrivalTime: rng.IntN(a.maxArrival + 1),
+			BurstTime:   1 + rng.IntN(a.maxBurst),
+		}
+		if a.priorities {
+			procs[i].Priority = 1 + rng.IntN(a.req.MaxPriority)
+		}
+	}
+	return procs
+}
+
+// Run every restart and keep the worst workload found; ties go to the
+// earlier restart
+func (a *adversary) search() AdversaryResponse {
+	bests := make([]candidate, a.req.Restarts)
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, runtime.NumCPU())
+	for r := range bests {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(r int) {
+			defer wg.Done()
+			bests[r] = a.climb(r)
+			<-slots
+		}(r)
+	}
+	wg.Wait()
+
+	worst := 0
+	response := AdversaryResponse{
+		Seed:        *a.req.Seed,
+		Objective:   a.metric.name,
+		Gap:         a.req.Gap,
+		Evaluations: int(a.simulations.Load()),
+	}
+	for r, best := range bests {
+		response.RestartScores = append(response.RestartScores, best.score)
+		if best.key > bests[worst].key {
+			worst = r
+		}
+	}
+	best := bests[worst]
+	response.Score = best.score
+	response.TargetValue = best.targetValue
+	response.Processes = generatedProcesses(best.procs)
+	response.Result = best.target
+	if best.baseline != nil {
+		response.BaselineValue = &best.baselineValue
+		response.BaselineResult = best.baseline
+	}
+	return response
+}
+
+// One restart of hill climbing: mutate one process at a time and keep the
+// change unless it makes the target do better. Sideways moves are kept so
+// the climb can cross plateaus.
+func (a *adversary) climb(restart int) candidate {
+	rng := a.rng(restart)
+	current := a.evaluate(a.start(restart, rng))
+	for i := 1; i < a.req.Iterations; i++ {
+		next := a.mutate(current.procs, rng)
+		if c := a.evaluate(next); c.key >= current.key {
+			current = c
+		}
+	}
+	return current
+}
+
+// A copy of the workload with one process changed
+func (a *adversary) mutate(procs []Process, rng *rand.Rand) []Process {
+	next := cloneProcesses(procs)
+	p := &next[rng.IntN(len(next))]
+	moves := 5
+	if a.priorities {
+		moves++
+	}
+	// Small steps, a tenth of the range either way
+	step := func(v, lo, hi int) int {
+		d := (hi-lo)/10 + 1
+		v += rng.IntN(2*d+1) - d
+		return min(max(v, lo), hi)
+	}
+	switch rng.IntN(moves) {
+	case 0:
+		p.ArrivalTime = rng.IntN(a.maxArrival + 1)
+	case 1:
+		p.BurstTime = 1 + rng.IntN(a.maxBurst)
+	case 2:
+		p.ArrivalTime = step(p.ArrivalTime, 0, a.maxArrival)
+	case 3:
+		p.BurstTime = step(p.BurstTime, 1, a.maxBurst)
+	case 4:
+		// Arriving together with another process makes ties to break
+		p.ArrivalTime = next[rng.IntN(len(next))].ArrivalTime
+	case 5:
+		p.Priority = 1 + rng.IntN(a.req.MaxPriority)
+	}
+	return next
+}
+
+// Simulate a workload under the target, and the baseline if there is one,
+// and score it
+func (a *adversary) evaluate(procs []Process) candidate {
+	base := a.req.SimulationRequest
+	base.Processes = procs
+	c := candidate{procs: procs}
+	c.target = simulateValidated(runRequest(base, a.req.Target), a.scale)
+	a.simulations.Add(1)
+	c.targetValue = a.metric.report(c.target, a.scale)
+	if a.req.Baseline == nil {
+		c.score = c.targetValue
+		c.key = c.score
+		if a.metric.higherBetter {
+			c.key = -c.score
+		}
+		return c
+	}
+
+	result := simulateValidated(runRequest(base, *a.req.Baseline), a.scale)
+	a.simulations.Add(1)
+	c.baseline = &result
+	c.baselineValue = a.metric.report(result, a.scale)
+	worse, better := c.targetValue, c.baselineValue
+	if a.metric.higherBetter {
+		worse, better = better, worse
+	}
+	if a.req.Gap == "difference" {
+		c.score = worse - better
+	} else if better > 0 {
+		// The ratio is undefined when the better side is 0, so such
+		// workloads score nothing
+		c.score = worse / better
+	}
+	c.key = c.score
+	return c
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+// Bounds on the search options are checked before anything multiplies or
+// draws from them, so extreme values are rejected rather than overflowing
+func TestAdversaryBounds(t *testing.T) {
+	huge := math.MaxInt64
+	cases := []struct {
+		name  string
+		edit  func(*AdversaryRequest)
+		field string // Field with the error, or "" when accepted
+	}{
+		{name: "defaults"},
+		{name: "restarts overflow product", edit: func(r *AdversaryRequest) { r.Restarts, r.Iterations = 1<<32, 1<<32 }, field: "restarts"},
+		{name: "iterations too many", edit: func(r *AdversaryRequest) { r.Restarts, r.Iterations = 1, maxSearchEvaluations+1 }, field: "iterations"},
+		{name: "budget product", edit: func(r *AdversaryRequest) { r.Restarts, r.Iterations = 200, 200 }, field: "iterations"},
+		{name: "budget without baseline", edit: func(r *AdversaryRequest) { r.Restarts, r.Iterations = 100, 150 }},
+		{name: "budget with baseline", edit: func(r *AdversaryRequest) {
+			// Each workload is simulated twice, so 15,000 of them are 30,000 runs
+			r.Restarts, r.Iterations = 100, 150
+			r.Baseline = &AlgorithmConfig{Algorithm: "SJF"}
+		}, field: "iterations"},
+		{name: "max arrival time", edit: func(r *AdversaryRequest) { r.MaxArrivalTime = &huge }, field: "maxArrivalTime"},
+		{name: "max burst time", edit: func(r *AdversaryRequest) { r.MaxBurstTime = huge }, field: "maxBurstTime"},
+		{name: "max priority", edit: func(r *AdversaryRequest) { r.MaxPriority = huge }, field: "maxPriority"},
+		{name: "stepped work", edit: func(r *AdversaryRequest) {
+			// The starting workload is small; the ones a search may reach are not
+			r.Processes = []Process{{ID: "P1", BurstTime: 1}, {ID: "P2", BurstTime: 1}}
+			r.Target = AlgorithmConfig{Algorithm: "VRR", TimeQuantum: 1}
+			r.MaxBurstTime = maxSteppedTicks
+		}, field: "maxBurstTime"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			seed := int64(1)
+			req := AdversaryRequest{Target: AlgorithmConfig{Algorithm: "FCFS"}, Objective: "averageWaitingTime", Seed: &seed}
+			if tc.edit != nil {
+				tc.edit(&req)
+			}
+			_, errs := newAdversary(req, map[string]interface{}{}, 1)
+			switch {
+			case tc.field == "" && len(errs) > 0:
+				t.Fatalf("rejected: %v", errs)
+			case tc.field != "" && (len(errs) == 0 || errs[0].Field != tc.field):
+				t.Fatalf("errors %v, want one on %s", errs, tc.field)
+			}
+		})
+	}
+}
+
+// Evaluations counts every simulation, the baseline's included
+func TestAdversaryEvaluations(t *testing.T) {
+	for _, baseline := range []*AlgorithmConfig{nil, {Algorithm: "SJF"}} {
+		seed := int64(1)
+		req := AdversaryRequest{Target: AlgorithmConfig{Algorithm: "FCFS"}, Baseline: baseline, Objective: "averageWaitingTime",
+			Count: 3, Restarts: 2, Iterations: 3, Seed: &seed}
+		a, errs := newAdversary(req, map[string]interface{}{}, 1)
+		if len(errs) > 0 {
+			t.Fatal(errs)
+		}
+		want := 6
+		if baseline != nil {
+			want = 12
+		}
+		if got := a.search().Evaluations; got != want {
+			t.Errorf("baseline %v: %d evaluations, want %d", baseline, got, want)
+		}
+	}
+}
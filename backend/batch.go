@@ -0,0 +1,340 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Batch scheduling of jobs onto a pool of identical nodes, as in an HPC
+// cluster queue. Each job asks for Nodes nodes and gives a Walltime estimate
+// (default BurstTime); it actually runs for BurstTime. Jobs are considered
+// in FCFS order, and the algorithms differ in how later jobs may jump ahead:
+//
+//   - BatchFCFS: no backfilling, the queue head blocks everyone behind it
+//   - EASY: a later job may start now if it does not delay the head job's
+//     reservation
+//   - Conservative: a later job may start now if it does not delay any
+//     earlier job's reservation
+//
+// Reservations are planned with the walltime estimates, so jobs that finish
+// early leave holes that backfilling can fill.
+
+// NodeSegment is one node running one job
+type NodeSegment struct {
+	Node      int    `json:"node"`
+	ProcessID string `json:"processId"`
+	StartTime int    `json:"startTime" time:"ticks"`
+	EndTime   int    `json:"endTime" time:"ticks"`
+}
+
+// BatchReport summarizes a batch run
+type BatchReport struct {
+	Nodes                  int           `json:"nodes"`
+	Makespan               int           `json:"makespan" time:"ticks"`
+	Utilization            float64       `json:"utilization"`
+	AverageBoundedSlowdown float64       `json:"averageBoundedSlowdown"`
+	MaxBoundedSlowdown     float64       `json:"maxBoundedSlowdown"`
+	NodeOccupancy          []NodeSegment `json:"nodeOccupancy"`
+}
+
+// Default threshold below which runtimes count as this long when computing
+// bounded slowdown, so very short jobs do not dominate the average
+const defaultSlowdownBound = 10
+
+// Check that every job fits the cluster and does not outrun its estimate
+func validateBatch(req SimulationRequest) []FieldError {
+	var errs []FieldError
+	if req.Nodes <= 0 {
+		errs = append(errs, fieldError("nodes", codeNotPositive, "Cluster must have at least one node"))
+	}
+	for i, p := range req.Processes {
+		if req.Nodes > 0 && p.Nodes > req.Nodes {
+			errs = append(errs, fieldError(fmt.Sprintf("processes[%d].nodes", i), codeOutOfRange, "Job requests more nodes than the cluster has"))
+		}
+		if p.Walltime > 0 && p.BurstTime > p.Walltime {
+			errs = append(errs, fieldError(fmt.Sprintf("processes[%d].burstTime", i), codeOutOfRange, "Burst time cannot exceed walltime"))
+		}
+	}
+	return errs
+}
+
+func jobNodes(p Process) int {
+	if p.Nodes <= 0 {
+		return 1
+	}
+	return p.Nodes
+}
+
+func jobWalltime(p Process) int {
+	if p.Walltime <= 0 {
+		return p.BurstTime
+	}
+	return p.Walltime
+}
+
+// availability is a step function of free nodes over time, used to plan
+// reservations. free[k] nodes are free from times[k] until times[k+1].
+type availability struct {
+	times []int
+	free  []int
+}
+
+// Free nodes from now on, given the running jobs' estimated end times
+func newAvailability(now, total int, ends []int, nodes []int) *availability {
+	a := &availability{times: []int{now}, free: []int{total}}
+	for k, end := range ends {
+		a.reserve(now, end, nodes[k])
+	}
+	return a
+}
+
+// Split the step function so that a step starts at time t
+func (a *availability) split(t int) int {
+	k := sort.SearchInts(a.times, t)
+	if k < len(a.times) && a.times[k] == t {
+		return k
+	}
+	a.times = append(a.times, 0)
+	a.free = append(a.free, 0)
+	copy(a.times[k+1:], a.times[k:])
+	copy(a.free[k+1:], a.free[k:])
+	a.times[k] = t
+	a.free[k] = a.free[k-1]
+	return k
+}
+
+// Take nodes out of the pool over [start, end)
+func (a *availability) reserve(start, end, nodes int) {
+	if end <= start {
+		return
+	}
+	from := a.split(start)
+	to := a.split(end)
+	for k := from; k < to; k++ {
+		a.free[k] -= nodes
+	}
+}
+
+// Earliest time at or after now when nodes are free for the whole duration
+func (a *availability) earliest(nodes, duration int) int {
+	for k := range a.times {
+		start := a.times[k]
+		fits := true
+		for j := k; j < len(a.times) && a.times[j] < start+duration; j++ {
+			if a.free[j] < nodes {
+				fits = false
+				break
+			}
+		}
+		if fits {
+			return start
+		}
+	}
+	return a.times[len(a.times)-1]
+}
+
+// Schedule a batch workload with FCFS, EASY backfilling or conservative
+// backfilling
+func runBatch(req SimulationRequest) SimulationResponse {
+	procs := make([]Process, len(req.Processes))
+	copy(procs, req.Processes)
+
+	// Queue order is arrival order, ties broken by the tie-breaker
+	tb := requestTieBreaker(req)
+	order := make([]int, len(procs))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		pa, pb := procs[order[a]], procs[order[b]]
+		if pa.ArrivalTime != pb.ArrivalTime {
+			return pa.ArrivalTime < pb.ArrivalTime
+		}
+		return tb.before(procs, order[a], order[b])
+	})
+
+	nodeBusy := make([]bool, req.Nodes)
+	freeNodes := req.Nodes
+	var queue []int   // Waiting jobs, in FCFS order
+	var running []int // Jobs on the cluster
+	jobNodeIDs := make([][]int, len(procs))
+	var occupancy []NodeSegment
+	var timeline []TimelineSegment
+
+	start := func(i, now int) {
+		p := &procs[i]
+		for n := 0; n < req.Nodes && len(jobNodeIDs[i]) < jobNodes(*p); n++ {
+			if !nodeBusy[n] {
+				nodeBusy[n] = true
+				jobNodeIDs[i] = append(jobNodeIDs[i], n)
+			}
+		}
+		freeNodes -= jobNodes(*p)
+		p.StartTime = now
+		p.IsStarted = true
+		p.ResponseTime = now - p.ArrivalTime
+		p.WaitingTime = p.ResponseTime
+		p.CompletionTime = now + p.BurstTime
+		p.TurnaroundTime = p.CompletionTime - p.ArrivalTime
+		running = append(running, i)
+
+		timeline = append(timeline, TimelineSegment{ProcessID: p.ID, StartTime: now, EndTime: p.CompletionTime})
+		for _, n := range jobNodeIDs[i] {
+			occupancy = append(occupancy, NodeSegment{Node: n, ProcessID: p.ID, StartTime: now, EndTime: p.CompletionTime})
+		}
+	}
+
+	next := 0 // Next job in arrival order that has not arrived yet
+	for next < len(order) || len(queue) > 0 || len(running) > 0 {
+		// Jump to the next arrival or completion
+		now := -1
+		if next < len(order) {
+			now = procs[order[next]].ArrivalTime
+		}
+		for _, i := range running {
+			if now == -1 || procs[i].CompletionTime < now {
+				now = procs[i].CompletionTime
+			}
+		}
+		if now == -1 {
+			break // Nothing running and nothing left to arrive
+		}
+
+		// Finished jobs free their nodes
+		var still []int
+		for _, i := range running {
+			if procs[i].CompletionTime <= now {
+				for _, n := range jobNodeIDs[i] {
+					nodeBusy[n] = false
+				}
+				freeNodes += jobNodes(procs[i])
+			} else {
+				still = append(still, i)
+			}
+		}
+		running = still
+
+		for next < len(order) && procs[order[next]].ArrivalTime <= now {
+			queue = append(queue, order[next])
+			next++
+		}
+
+		// Start whatever the algorithm allows
+		var waiting []int
+		switch req.Algorithm {
+		case "BatchFCFS", "EASY":
+			// Start jobs from the head of the queue while they fit
+			k := 0
+			for k < len(queue) && jobNodes(procs[queue[k]]) <= freeNodes {
+				start(queue[k], now)
+				k++
+			}
+			queue = queue[k:]
+			if req.Algorithm == "BatchFCFS" || len(queue) == 0 {
+				break
+			}
+
+			// Reserve the earliest start for the head job: the shadow time.
+			// Nodes left over at that time are extra nodes that backfilled
+			// jobs may keep past the shadow time.
+			head := queue[0]
+			ends := make([]int, 0, len(running))
+			nodes := make([]int, 0, len(running))
+			for _, i := range running {
+				ends = append(ends, procs[i].StartTime+jobWalltime(procs[i]))
+				nodes = append(nodes, jobNodes(procs[i]))
+			}
+			avail := newAvailability(now, req.Nodes, ends, nodes)
+			shadow := avail.earliest(jobNodes(procs[head]), jobWalltime(procs[head]))
+			extra := avail.free[avail.split(shadow)] - jobNodes(procs[head])
+
+			waiting = append(waiting, head)
+			for _, i := range queue[1:] {
+				n := jobNodes(procs[i])
+				switch {
+				case n > freeNodes:
+					waiting = append(waiting, i)
+				case now+jobWalltime(procs[i]) <= shadow:
+					start(i, now)
+				case n <= extra:
+					extra -= n
+					start(i, now)
+				default:
+					waiting = append(waiting, i)
+				}
+			}
+			queue = waiting
+		case "Conservative":
+			// Give every queued job, in order, the earliest reservation that
+			// does not disturb the ones before it; start those due now
+			ends := make([]int, 0, len(running))
+			nodes := make([]int, 0, len(running))
+			for _, i := range running {
+				ends = append(ends, procs[i].StartTime+jobWalltime(procs[i]))
+				nodes = append(nodes, jobNodes(procs[i]))
+			}
+			avail := newAvailability(now, req.Nodes, ends, nodes)
+			for _, i := range queue {
+				at := avail.earliest(jobNodes(procs[i]), jobWalltime(procs[i]))
+				avail.reserve(at, at+jobWalltime(procs[i]), jobNodes(procs[i]))
+				if at == now && jobNodes(procs[i]) <= freeNodes {
+					start(i, now)
+				} else {
+					waiting = append(waiting, i)
+				}
+			}
+			queue = waiting
+		}
+	}
+
+	response := SimulationResponse{
+		Processes: procs,
+		Timeline:  timeline,
+		Batch:     batchReport(req, procs, occupancy),
+	}
+	return response
+}
+
+// Utilization and bounded slowdown of a finished batch run
+func batchReport(req SimulationRequest, procs []Process, occupancy []NodeSegment) *BatchReport {
+	bound := req.SlowdownBound
+	if bound <= 0 {
+		bound = defaultSlowdownBound
+	}
+
+	report := &BatchReport{Nodes: req.Nodes, NodeOccupancy: occupancy}
+	first, last, work := -1, 0, 0
+	var totalSlowdown float64
+	for i := range procs {
+		p := &procs[i]
+		if first == -1 || p.ArrivalTime < first {
+			first = p.ArrivalTime
+		}
+		if p.CompletionTime > last {
+			last = p.CompletionTime
+		}
+		work += p.BurstTime * jobNodes(*p)
+
+		runtime := p.BurstTime
+		if runtime < bound {
+			runtime = bound
+		}
+		p.BoundedSlowdown = float64(p.WaitingTime+p.BurstTime) / float64(runtime)
+		if p.BoundedSlowdown < 1 {
+			p.BoundedSlowdown = 1
+		}
+		totalSlowdown += p.BoundedSlowdown
+		if p.BoundedSlowdown > report.MaxBoundedSlowdown {
+			report.MaxBoundedSlowdown = p.BoundedSlowdown
+		}
+	}
+
+	report.Makespan = last - first
+	if report.Makespan > 0 {
+		report.Utilization = float64(work) / float64(report.Makespan*req.Nodes)
+	}
+	if len(procs) > 0 {
+		report.AverageBoundedSlowdown = totalSlowdown / float64(len(procs))
+	}
+	return report
+}
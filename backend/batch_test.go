@@ -0,0 +1,58 @@
+package main
+
+import "testing"
+
+func TestBatchSchedules(t *testing.T) {
+	makespan := func(want int) func(t *testing.T, r SimulationResponse) {
+		return func(t *testing.T, r SimulationResponse) {
+			if r.Batch == nil || r.Batch.Makespan != want {
+				t.Errorf("batch report %+v, want makespan %d", r.Batch, want)
+			}
+		}
+	}
+	// J2 is reserved all 3 free nodes at 10 and J3 the whole cluster at 12.
+	// J4 fits beside J2 but runs past J3's reservation, so only EASY, which
+	// protects the head job alone, backfills it.
+	diverging := `"nodes": 4, "processes": [{"id": "J1", "nodes": 3, "burstTime": 10}, {"id": "J2", "arrivalTime": 1, "nodes": 3, "burstTime": 2}, {"id": "J3", "arrivalTime": 2, "nodes": 4, "burstTime": 2}, {"id": "J4", "arrivalTime": 3, "nodes": 1, "burstTime": 12}]}`
+	runScheduleCases(t, []scheduleCase{
+		{
+			// J4 ends before the head job's reservation at 10 and backfills;
+			// J3 would run past it and waits
+			name:     "easy keeps the head reservation",
+			body:     `{"algorithm": "EASY", "nodes": 4, "processes": [{"id": "J1", "nodes": 2, "burstTime": 10}, {"id": "J2", "arrivalTime": 1, "nodes": 4, "burstTime": 5}, {"id": "J3", "arrivalTime": 2, "nodes": 1, "burstTime": 20}, {"id": "J4", "arrivalTime": 3, "nodes": 2, "burstTime": 5}]}`,
+			timeline: "J1 0-10, J4 3-8, J2 10-15, J3 15-35",
+			times:    "J1 0/10, J2 9/14, J3 13/33, J4 0/5",
+			check:    makespan(35),
+		},
+		{
+			name:     "fcfs",
+			body:     `{"algorithm": "BatchFCFS", ` + diverging,
+			timeline: "J1 0-10, J2 10-12, J3 12-14, J4 14-26",
+			times:    "J1 0/10, J2 9/11, J3 10/12, J4 11/23",
+			check:    makespan(26),
+		},
+		{
+			name:     "easy",
+			body:     `{"algorithm": "EASY", ` + diverging,
+			timeline: "J1 0-10, J4 3-15, J2 10-12, J3 15-17",
+			times:    "J1 0/10, J2 9/11, J3 13/15, J4 0/12",
+			check:    makespan(17),
+		},
+		{
+			name:     "conservative",
+			body:     `{"algorithm": "Conservative", ` + diverging,
+			timeline: "J1 0-10, J2 10-12, J3 12-14, J4 14-26",
+			times:    "J1 0/10, J2 9/11, J3 10/12, J4 11/23",
+			check:    makespan(26),
+		},
+		{
+			// Reservations use the walltime estimate: J3 fits before J2's
+			// reservation at 10, and J1 finishing early does not change that
+			name:     "walltime estimate",
+			body:     `{"algorithm": "EASY", "nodes": 2, "processes": [{"id": "J1", "nodes": 1, "burstTime": 3, "walltime": 10}, {"id": "J2", "arrivalTime": 1, "nodes": 2, "burstTime": 2}, {"id": "J3", "arrivalTime": 2, "nodes": 1, "burstTime": 4}]}`,
+			timeline: "J1 0-3, J3 2-6, J2 6-8",
+			times:    "J1 0/3, J2 5/7, J3 0/4",
+			check:    makespan(8),
+		},
+	})
+}
@@ -0,0 +1,148 @@
+package main
+
+import "fmt"
+
+// Cgroup caps the CPU time its processes may use, like cgroup v2 cpu.max:
+// together they get at most Quota time units in every Period. Periods start
+// at multiples of Period from time 0.
+type Cgroup struct {
+	ID     string `json:"id"`
+	Quota  int    `json:"quota" time:"ticks"`
+	Period int    `json:"period" time:"ticks"`
+}
+
+// CgroupStats mirrors the throttling counters of cpu.stat for one cgroup
+type CgroupStats struct {
+	ID            string `json:"id"`
+	Quota         int    `json:"quota" time:"ticks"`
+	Period        int    `json:"period" time:"ticks"`
+	Usage         int    `json:"usage" time:"ticks"`
+	ThrottleCount int    `json:"throttleCount"`
+	ThrottledTime int    `json:"throttledTime" time:"ticks"`
+}
+
+// cgroupController tracks quota usage for the step engine and throttles a
+// cgroup once it has used up its quota for the current period
+type cgroupController struct {
+	groups []Cgroup
+	index  map[string]int // Cgroup ID to position in groups
+
+	period    []int // Period number the usage below belongs to
+	used      []int // Time used in that period
+	throttled []bool
+	stats     []CgroupStats
+	segments  []TimelineSegment // Throttled intervals
+}
+
+func newCgroupController(groups []Cgroup) *cgroupController {
+	cc := &cgroupController{
+		groups:    groups,
+		index:     make(map[string]int),
+		period:    make([]int, len(groups)),
+		used:      make([]int, len(groups)),
+		throttled: make([]bool, len(groups)),
+		stats:     make([]CgroupStats, len(groups)),
+	}
+	for g, group := range groups {
+		cc.index[group.ID] = g
+		cc.stats[g] = CgroupStats{ID: group.ID, Quota: group.Quota, Period: group.Period}
+	}
+	return cc
+}
+
+// Check that cgroups are well formed and that every process refers to one
+// that exists, forked children included
+func validateCgroups(groups []Cgroup, processes []Process) []FieldError {
+	var errs []FieldError
+	known := make(map[string]bool)
+	for i, g := range groups {
+		if g.Quota <= 0 {
+			errs = append(errs, fieldError(fmt.Sprintf("cgroups[%d].quota", i), codeNotPositive, "Cgroup quota and period must be positive"))
+		}
+		if g.Period <= 0 {
+			errs = append(errs, fieldError(fmt.Sprintf("cgroups[%d].period", i), codeNotPositive, "Cgroup quota and period must be positive"))
+		}
+		if known[g.ID] {
+			errs = append(errs, fieldError(fmt.Sprintf("cgroups[%d].id", i), codeDuplicate, "Cgroup ID is already used"))
+		}
+		known[g.ID] = true
+	}
+	// Forked children may name a cgroup of their own, at any depth
+	var check func(path string, p Process)
+	check = func(path string, p Process) {
+		if p.Cgroup != "" && !known[p.Cgroup] {
+			errs = append(errs, fieldError(path+".cgroup", codeUnknown, "Unknown cgroup"))
+		}
+		for k, s := range p.Spawns {
+			check(fmt.Sprintf("%s.spawns[%d].child", path, k), s.Child)
+		}
+	}
+	for i, p := range processes {
+		check(fmt.Sprintf("processes[%d]", i), p)
+	}
+	return errs
+}
+
+// Start a fresh period for the cgroup if the clock has moved past its
+// current one
+func (cc *cgroupController) roll(g, now int) {
+	if p := now / cc.groups[g].Period; p != cc.period[g] {
+		cc.period[g] = p
+		cc.used[g] = 0
+		cc.throttled[g] = false
+	}
+}
+
+// Whether a process in the given cgroup may run at time now. The first
+// refusal in a period counts as a throttle and is recorded in the timeline
+// until the period ends.
+func (cc *cgroupController) allows(cgroup string, now int) bool {
+	g, ok := cc.index[cgroup]
+	if !ok {
+		return true // Not in any cgroup
+	}
+	cc.roll(g, now)
+	if cc.used[g] < cc.groups[g].Quota {
+		return true
+	}
+
+	if !cc.throttled[g] {
+		cc.throttled[g] = true
+		end := (cc.period[g] + 1) * cc.groups[g].Period
+		cc.stats[g].ThrottleCount++
+		cc.stats[g].ThrottledTime += end - now
+		cc.segments = append(cc.segments, TimelineSegment{
+			StartTime: now,
+			EndTime:   end,
+			Kind:      "throttled",
+			Cgroup:    cc.groups[g].ID,
+		})
+	}
+	return false
+}
+
+// Charge one time unit of CPU to a cgroup
+func (cc *cgroupController) charge(cgroup string, now int) {
+	g, ok := cc.index[cgroup]
+	if !ok {
+		return
+	}
+	cc.roll(g, now)
+	cc.used[g]++
+	cc.stats[g].Usage++
+}
+
+// Earliest time a throttled cgroup gets its quota back, or -1
+func (cc *cgroupController) nextUnthrottle() int {
+	next := -1
+	for g, group := range cc.groups {
+		if !cc.throttled[g] {
+			continue
+		}
+		end := (cc.period[g] + 1) * group.Period
+		if next == -1 || end < next {
+			next = end
+		}
+	}
+	return next
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCgroupQuota(t *testing.T) {
+	runScheduleCases(t, []scheduleCase{
+		{
+			// A uses its 2 ticks in each 5-tick period and sits throttled
+			// for the other 3, while B, outside the group, keeps running
+			name:     "throttled",
+			body:     `{"algorithm": "FCFS", "cgroups": [{"id": "g", "quota": 2, "period": 5}], "processes": [{"id": "A", "burstTime": 5, "cgroup": "g"}, {"id": "B", "burstTime": 2}]}`,
+			timeline: "A 0-2, B 2-4, throttled g 2-5, A 5-7, throttled g 7-10, A 10-11",
+			times:    "A 6/11, B 2/4",
+			check: func(t *testing.T, r SimulationResponse) {
+				want := []CgroupStats{{ID: "g", Quota: 2, Period: 5, Usage: 5, ThrottleCount: 2, ThrottledTime: 6}}
+				if !reflect.DeepEqual(r.CgroupStats, want) {
+					t.Errorf("cgroup stats %+v, want %+v", r.CgroupStats, want)
+				}
+			},
+		},
+	})
+}
+
+func TestCgroupReferences(t *testing.T) {
+	groups := []Cgroup{{ID: "g", Quota: 2, Period: 5}}
+	processes := []Process{
+		{ID: "A", BurstTime: 3, Cgroup: "g", Spawns: []Spawn{{Offset: 1, Child: Process{ID: "C", BurstTime: 1, Cgroup: "g",
+			Spawns: []Spawn{{Child: Process{ID: "D", BurstTime: 1, Cgroup: "missing"}}}}}}},
+		{ID: "B", BurstTime: 1, Cgroup: "other"},
+	}
+	var got []string
+	for _, e := range validateCgroups(groups, processes) {
+		got = append(got, e.Field)
+	}
+	want := []string{"processes[0].spawns[0].child.spawns[0].child.cgroup", "processes[1].cgroup"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unknown cgroups at %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Running several algorithms on one workload and ranking them side by side.
+// A comparison request carries every option of a simulation request, shared
+// by all runs, plus a list of algorithm configs that override the
+// algorithm-specific ones.
+
+// Most runs one comparison may ask for
+const maxCompareRuns = 20
+
+type CompareRequest struct {
+	SimulationRequest
+	Algorithms []AlgorithmConfig `json:"algorithms"`
+}
+
+// AlgorithmConfig is one run of a comparison. Options left unset fall back
+// to the ones shared by the whole request. Name labels the run in the
+// rankings and defaults to a description of the config.
+type AlgorithmConfig struct {
+	Name          string           `json:"name,omitempty"`
+	Algorithm     string           `json:"algorithm"`
+	IsPreemptive  bool             `json:"isPreemptive,omitempty"`
+	TimeQuantum   int              `json:"timeQuantum,omitempty" time:"ticks"`
+	QuantumRule   string           `json:"quantumRule,omitempty"`
+	RRQueueOrder  string           `json:"rrQueueOrder,omitempty"`
+	PriorityOrder string           `json:"priorityOrder,omitempty"`
+	SelfishRR     *SelfishRRConfig `json:"selfishRR,omitempty"`
+}
+
+// Request keys an AlgorithmConfig overrides, so validation errors about
+// them can point at the run rather than the shared options
+var algorithmConfigFields = map[string]bool{
+	"algorithm": true, "isPreemptive": true, "timeQuantum": true, "quantumRule": true,
+	"rrQueueOrder": true, "priorityOrder": true, "selfishRR": true,
+}
+
+type CompareResponse struct {
+	Runs     []CompareRun    `json:"runs"`
+	Rankings []MetricRanking `json:"rankings"`
+}
+
+// CompareRun is one run's config and full result
+type CompareRun struct {
+	Name   string             `json:"name"`
+	Config AlgorithmConfig    `json:"config"`
+	Result SimulationResponse `json:"result"`
+}
+
+// MetricRanking orders the runs by one metric, best first. Runs with equal
+// values share a rank, and every run ranked first is a winner.
+type MetricRanking struct {
+	Metric  string      `json:"metric"`
+	Better  string      `json:"better"` // "lower" or "higher"
+	Ranking []RankEntry `json:"ranking"`
+	Winners []string    `json:"winners"`
+}
+
+type RankEntry struct {
+	Run   string  `json:"run"`
+	Value float64 `json:"value"`
+	Rank  int     `json:"rank"`
+}
+
+// compareMetric is a metric the runs are ranked by. Time metrics are taken
+// in ticks and reported in the time unit.
+type compareMetric struct {
+	name         string
+	higherBetter bool
+	isTime       bool
+	value        func(r SimulationResponse) float64
+}
+
+var compareMetrics = []compareMetric{
+	{"averageWaitingTime", false, true, func(r SimulationResponse) float64 { return r.AverageWaitingTime }},
+	{"averageTurnaroundTime", false, true, func(r SimulationResponse) float64 { return r.AverageTurnaroundTime }},
+	{"averageResponseTime", false, true, func(r SimulationResponse) float64 { return r.AverageResponseTime }},
+	{"p95WaitingTime", false, true, func(r SimulationResponse) float64 {
+		if r.Metrics.Distributions == nil {
+			return 0
+		}
+		return float64(r.Metrics.Distributions.WaitingTime.P95)
+	}},
+	{"p95ResponseTime", false, true, func(r SimulationResponse) float64 {
+		if r.Metrics.Distributions == nil {
+			return 0
+		}
+		return float64(r.Metrics.Distributions.ResponseTime.P95)
+	}},
+	{"makespan", false, true, func(r SimulationResponse) float64 { return float64(r.Metrics.Makespan) }},
+	{"cpuUtilization", true, false, func(r SimulationResponse) float64 { return r.Metrics.CPUUtilization }},
+	{"throughput", true, false, func(r SimulationResponse) float64 { return r.Metrics.Throughput }},
+	{"contextSwitches", false, false, func(r SimulationResponse) float64 { return float64(r.Metrics.ContextSwitches) }},
+	{"preemptions", false, false, func(r SimulationResponse) float64 { return float64(r.Metrics.Preemptions) }},
+	{"jainIndex", true, false, func(r SimulationResponse) float64 { return r.Metrics.Fairness.JainIndex }},
+	{"maxSlowdown", false, false, func(r SimulationResponse) float64 { return r.Metrics.Fairness.MaxSlowdown }},
+	{"maxReadyWait", false, true, func(r SimulationResponse) float64 { return float64(r.Metrics.Fairness.MaxReadyWait) }},
+}
+
+// The ranking metric with the given name, or nil
+func findCompareMetric(name string) *compareMetric {
+	for i := range compareMetrics {
+		if compareMetrics[i].name == name {
+			return &compareMetrics[i]
+		}
+	}
+	return nil
+}
+
+// Value of the metric for a run, in the time unit for time metrics
+func (m compareMetric) report(r SimulationResponse, scale int64) float64 {
+	v := m.value(r)
+	if m.isTime {
+		v /= float64(scale)
+	}
+	return v
+}
+
+// "lower" or "higher", whichever is better for the metric
+func better(m compareMetric) string {
+	if m.higherBetter {
+		return "higher"
+	}
+	return "lower"
+}
+
+func handleCompare(c *gin.Context) {
+	var req CompareRequest
+	scale, raw, ok := decodeBody(c, &req)
+	if !ok {
+		return
+	}
+	if errs := validateCompare(req, raw); len(errs) > 0 {
+		rejectRequest(c, errs)
+		return
+	}
+
+	respond(c, compare(req, scale), req.TimeResolution)
+}
+
+// The simulation request for one run of a comparison, with its own copy of
+// the processes so runs can go concurrently
+func runRequest(base SimulationRequest, cfg AlgorithmConfig) SimulationRequest {
+	req := base
+	req.Processes = cloneProcesses(base.Processes)
+	req.Algorithm = cfg.Algorithm
+	req.IsPreemptive = cfg.IsPreemptive
+	if cfg.TimeQuantum != 0 {
+		req.TimeQuantum = cfg.TimeQuantum
+	}
+	if cfg.QuantumRule != "" {
+		req.QuantumRule = cfg.QuantumRule
+	}
+	if cfg.RRQueueOrder != "" {
+		req.RRQueueOrder = cfg.RRQueueOrder
+	}
+	if cfg.PriorityOrder != "" {
+		req.PriorityOrder = cfg.PriorityOrder
+	}
+	if cfg.SelfishRR != nil {
+		req.SelfishRR = cfg.SelfishRR
+	}
+	return req
+}
+
+// Deep copy of processes, including the threads and forked children the
+// simulators fill in
+func cloneProcesses(processes []Process) []Process {
+	procs := make([]Process, len(processes))
+	copy(procs, processes)
+	for i := range procs {
+		if procs[i].Threads != nil {
+			procs[i].Threads = append([]Thread(nil), procs[i].Threads...)
+		}
+		if procs[i].Spawns != nil {
+			spawns := make([]Spawn, len(procs[i].Spawns))
+			copy(spawns, procs[i].Spawns)
+			for k := range spawns {
+				spawns[k].Child = cloneProcesses([]Process{spawns[k].Child})[0]
+			}
+			procs[i].Spawns = spawns
+		}
+	}
+	return procs
+}
+
+// Check a comparison: the list of runs, and every run as the simulation
+// request it turns into. Errors that every run shares are reported once.
+func validateCompare(req CompareRequest, raw map[string]interface{}) []FieldError {
+	var errs []FieldError
+	switch {
+	case len(req.Algorithms) == 0:
+		errs = append(errs, fieldError("algorithms", codeRequired, "At least one algorithm is required"))
+	case len(req.Algorithms) > maxCompareRuns:
+		errs = append(errs, fieldError("algorithms", codeOutOfRange, fmt.Sprintf("At most %d algorithms can be compared at once", maxCompareRuns)))
+	}
+
+	names := make(map[string]bool)
+	seen := make(map[FieldError]bool)
+	for i, cfg := range req.Algorithms {
+		path := fmt.Sprintf("algorithms[%d]", i)
+		if cfg.Name != "" {
+			if names[cfg.Name] {
+				errs = append(errs, fieldError(path+".name", codeDuplicate, "Run name is already used"))
+			}
+			names[cfg.Name] = true
+		}
+		for _, e := range validateRun(req.SimulationRequest, cfg, path, raw) {
+			if !seen[e] {
+				seen[e] = true
+				errs = append(errs, e)
+			}
+		}
+	}
+	return errs
+}
+
+// Check the simulation request one config turns into, pointing errors about
+// the options it overrides at path
+func validateRun(base SimulationRequest, cfg AlgorithmConfig, path string, raw map[string]interface{}) []FieldError {
+	errs := validateRequest(runRequest(base, cfg), raw)
+	for i, e := range errs {
+		key := e.Field
+		if k := strings.IndexAny(key, ".["); k >= 0 {
+			key = key[:k]
+		}
+		if algorithmConfigFields[key] {
+			errs[i].Field = path + "." + e.Field
+		}
+	}
+	return errs
+}
+
+// Run every config concurrently and rank the results
+func compare(req CompareRequest, scale int64) CompareResponse {
+	runs := make([]CompareRun, len(req.Algorithms))
+	var wg sync.WaitGroup
+	for i, cfg := range req.Algorithms {
+		wg.Add(1)
+		go func(i int, cfg AlgorithmConfig) {
+			defer wg.Done()
+			runs[i] = CompareRun{Config: cfg, Result: simulateValidated(runRequest(req.SimulationRequest, cfg), scale)}
+		}(i, cfg)
+	}
+	wg.Wait()
+
+	for i, name := range runNames(req.Algorithms, scale) {
+		runs[i].Name = name
+	}
+	return CompareResponse{Runs: runs, Rankings: rankRuns(runs, scale)}
+}
+
+// Names of the runs, made unique where configs describe the same run
+func runNames(configs []AlgorithmConfig, scale int64) []string {
+	names := make([]string, len(configs))
+	used := make(map[string]int)
+	for i, cfg := range configs {
+		name := cfg.Name
+		if name == "" {
+			name = describeConfig(cfg, scale)
+		}
+		used[name]++
+		if n := used[name]; n > 1 {
+			name = fmt.Sprintf("%s #%d", name, n)
+		}
+		names[i] = name
+	}
+	return names
+}
+
+// Short description of a config, such as "SJF (preemptive)" or "RR q=2"
+func describeConfig(cfg AlgorithmConfig, scale int64) string {
+	name := cfg.Algorithm
+	if cfg.IsPreemptive {
+		name += " (preemptive)"
+	}
+	if cfg.TimeQuantum > 0 {
+		name += " q=" + new(big.Rat).SetFrac64(int64(cfg.TimeQuantum), scale).RatString()
+	}
+	if cfg.QuantumRule != "" {
+		name += " " + cfg.QuantumRule
+	}
+	if cfg.RRQueueOrder != "" {
+		name += " " + cfg.RRQueueOrder
+	}
+	if cfg.PriorityOrder != "" {
+		name += " " + cfg.PriorityOrder
+	}
+	return name
+}
+
+// Rank the runs by every metric
+func rankRuns(runs []CompareRun, scale int64) []MetricRanking {
+	var rankings []MetricRanking
+	for _, m := range compareMetrics {
+		mr := MetricRanking{Metric: m.name, Better: better(m)}
+		for _, run := range runs {
+			mr.Ranking = append(mr.Ranking, RankEntry{Run: run.Name, Value: m.report(run.Result, scale)})
+		}
+		sort.SliceStable(mr.Ranking, func(a, b int) bool {
+			if m.higherBetter {
+				return mr.Ranking[a].Value > mr.Ranking[b].Value
+			}
+			return mr.Ranking[a].Value < mr.Ranking[b].Value
+		})
+		for i := range mr.Ranking {
+			mr.Ranking[i].Rank = i + 1
+			if i > 0 && mr.Ranking[i].Value == mr.Ranking[i-1].Value {
+				mr.Ranking[i].Rank = mr.Ranking[i-1].Rank
+			}
+			if mr.Ranking[i].Rank == 1 {
+				mr.Winners = append(mr.Winners, mr.Ranking[i].Run)
+			}
+		}
+		rankings = append(rankings, mr)
+	}
+	return rankings
+}
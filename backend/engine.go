@@ -0,0 +1,717 @@
+package main
+
+import (
+	"math"
+	"sort"
+)
+
+// The step engine advances a simulation one time unit at a time. The run*
+// functions in main.go assume every process is known up front and never
+// leaves the CPU except to finish or be preempted; the engine instead makes
+// a fresh decision at every time unit, so processes can appear (fork) and
+// block (wait, I/O) while the simulation is running.
+
+type procState int
+
+const (
+	stateNew     procState = iota // Has not arrived yet
+	stateReady                    // Waiting in the ready queue
+	stateRunning                  // On the CPU
+	stateBlocked                  // Waiting for children to exit or for I/O
+	stateDone                     // Finished
+)
+
+type engine struct {
+	algorithm     string
+	isPreemptive  bool
+	timeQuantum   int
+	inheritPolicy string
+	tieBreaker    tieBreaker
+
+	// Round Robin: queue a preempted process ahead of processes that became
+	// ready at the same instant
+	preemptedFirst bool
+
+	// Priority: whether higher numbers run first
+	higherFirst bool
+
+	procs     []Process
+	state     []procState
+	queue     []int // Ready processes in the order they became ready
+	readyAt   []int // When each process last joined the ready queue, indexed like procs
+	running   int   // Index of the process on the CPU, -1 when idle
+	sliceUsed int   // Time the running process has used of its quantum
+	time      int
+	timeline  []TimelineSegment
+
+	// Extra conditions a ready process must meet to be dispatched. Threading
+	// models use them to hide threads from the kernel.
+	filters []func(i int) bool
+
+	// CPU quotas, nil when the workload has no cgroups
+	cgroups *cgroupController
+
+	// Group/user hierarchy, only used by the FairShare algorithm
+	shares *shareTree
+
+	// Linux scheduling class state and RT throttling, only used by the
+	// Linux algorithm; rtBandwidth is nil when RT throttling is off
+	linux       *linuxSched
+	rtBandwidth *cgroupController
+
+	// Fork/wait bookkeeping, indexed like procs
+	parent    []int    // Index of the parent process, -1 for top-level processes
+	waitedBy  []bool   // Whether the parent blocks until this process exits
+	waitingOn []int    // Number of children this process is still waiting for
+	forked    [][]bool // forked[i][k] is set once procs[i].Spawns[k] has fired
+
+	// I/O bookkeeping, indexed like procs
+	ioStarted [][]bool // ioStarted[i][k] is set once procs[i].IOBursts[k] has begun
+	wakeAt    []int    // When the process's current I/O completes, -1 if none
+
+	// Virtual Round Robin: quantum a process had left when it blocked for
+	// I/O. While it is positive the process sits in the auxiliary queue,
+	// which is served before the main one.
+	vrrLeft []int
+}
+
+// Check whether a request needs the step engine rather than the run* functions
+func needsEngine(req SimulationRequest) bool {
+	return hasSpawns(req.Processes) || hasIO(req.Processes) || hasThreads(req.Processes) ||
+		len(req.Cgroups) > 0
+}
+
+// Most busy ticks a simulator that steps one tick at a time may run
+const maxSteppedTicks = 5000000
+
+// Busy ticks the request needs from a simulator that steps one tick at a
+// time, or 0 if it runs on an event-driven one. Idle gaps are skipped, so
+// only work counts, plus the horizon for real-time tasks. Counted in floats
+// so that huge values cannot overflow.
+func steppedTicks(req SimulationRequest) float64 {
+	switch {
+	case isRealtimeAlgorithm(req.Algorithm):
+		horizon := req.Horizon
+		if horizon <= 0 {
+			horizon = hyperperiod(req.Processes, req.Server, pow10(req.TimeResolution))
+		}
+		ticks := float64(horizon)
+		for _, p := range req.Processes {
+			switch {
+			case p.Period == 0:
+				ticks += float64(p.BurstTime)
+			case p.ArrivalTime < horizon:
+				releases := math.Ceil(float64(horizon-p.ArrivalTime) / float64(p.Period))
+				ticks += releases * float64(p.BurstTime)
+			}
+		}
+		return ticks
+	case needsEngine(req), req.Algorithm == "SelfishRR", req.Algorithm == "LJF" && req.IsPreemptive,
+		req.Algorithm == "FairShare", req.Algorithm == "Linux", req.Algorithm == "VRR", req.Algorithm == "PriorityRR":
+		return totalWork(req.Processes)
+	}
+	return 0
+}
+
+// CPU time needed by the processes, their threads and every child they fork
+func totalWork(processes []Process) float64 {
+	work := 0.0
+	for _, p := range processes {
+		if len(p.Threads) == 0 {
+			work += float64(p.BurstTime)
+		}
+		for _, t := range p.Threads {
+			work += float64(t.BurstTime)
+		}
+		for _, s := range p.Spawns {
+			work += totalWork([]Process{s.Child})
+		}
+	}
+	return work
+}
+
+func hasIO(processes []Process) bool {
+	for _, p := range processes {
+		if len(p.IOBursts) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Algorithms the engine knows how to schedule
+func engineSupports(algorithm string) bool {
+	switch algorithm {
+	case "FCFS", "SJF", "LJF", "RR", "VRR", "Priority", "PriorityRR", "FairShare", "Linux":
+		return true
+	}
+	return false
+}
+
+func newEngine(req SimulationRequest) *engine {
+	e := &engine{
+		algorithm:     req.Algorithm,
+		isPreemptive:  req.IsPreemptive,
+		timeQuantum:   req.TimeQuantum,
+		inheritPolicy: req.InheritPolicy,
+		tieBreaker:    requestTieBreaker(req),
+		running:       -1,
+
+		preemptedFirst: req.RRQueueOrder == "preempted-first",
+		higherFirst:    req.PriorityOrder == "higher-first",
+	}
+	if e.timeQuantum <= 0 {
+		e.timeQuantum = 1 // Default time quantum, same as runRoundRobin
+	}
+	if len(req.Cgroups) > 0 {
+		e.cgroups = newCgroupController(req.Cgroups)
+	}
+	if req.Algorithm == "FairShare" {
+		e.shares = newShareTree(req.ShareGroups, req.ShareUsers, req.Processes)
+	}
+	if req.Algorithm == "Linux" {
+		e.linux = &linuxSched{}
+		// Like sched_rt_runtime_us/sched_rt_period_us: real-time tasks get at
+		// most RTRuntime of every RTPeriod
+		if req.RTRuntime > 0 && req.RTPeriod > 0 && req.RTRuntime < req.RTPeriod {
+			e.rtBandwidth = newCgroupController([]Cgroup{{
+				ID:     rtBandwidthGroup,
+				Quota:  req.RTRuntime,
+				Period: req.RTPeriod,
+			}})
+		}
+	}
+
+	for _, p := range req.Processes {
+		p.RemainingTime = p.BurstTime
+		p.IsStarted = false
+		e.add(p, -1, false)
+	}
+
+	// Start the clock at the earliest arrival
+	if len(e.procs) > 0 {
+		e.time = e.procs[0].ArrivalTime
+		for _, p := range e.procs {
+			if p.ArrivalTime < e.time {
+				e.time = p.ArrivalTime
+			}
+		}
+	}
+	return e
+}
+
+// Register a process with the engine and return its index
+func (e *engine) add(p Process, parent int, waited bool) int {
+	e.procs = append(e.procs, p)
+	e.state = append(e.state, stateNew)
+	e.parent = append(e.parent, parent)
+	e.waitedBy = append(e.waitedBy, waited)
+	e.waitingOn = append(e.waitingOn, 0)
+	e.forked = append(e.forked, make([]bool, len(p.Spawns)))
+	e.ioStarted = append(e.ioStarted, make([]bool, len(p.IOBursts)))
+	e.wakeAt = append(e.wakeAt, -1)
+	e.readyAt = append(e.readyAt, 0)
+	e.vrrLeft = append(e.vrrLeft, 0)
+	return len(e.procs) - 1
+}
+
+// Schedule a workload with the step engine
+func runEngine(req SimulationRequest) SimulationResponse {
+	e := newEngine(req)
+	e.run()
+	return e.response()
+}
+
+// Run the simulation until every process has finished
+func (e *engine) run() {
+	for !e.finished() {
+		e.admit()
+		e.dispatch()
+
+		// Nothing can run: jump ahead to the next arrival or I/O completion
+		if e.running == -1 {
+			next := e.nextEvent()
+			if next == -1 {
+				break // Only blocked processes remain, nothing can wake them
+			}
+			e.idle(next)
+			continue
+		}
+
+		e.step()
+	}
+}
+
+func (e *engine) finished() bool {
+	for _, s := range e.state {
+		if s != stateDone {
+			return false
+		}
+	}
+	return true
+}
+
+// Earliest future arrival, I/O completion or cgroup unthrottle, or -1 if
+// there is none
+func (e *engine) nextEvent() int {
+	next := -1
+	for i, p := range e.procs {
+		at := -1
+		if e.state[i] == stateNew {
+			at = p.ArrivalTime
+		} else if e.state[i] == stateBlocked && e.wakeAt[i] != -1 {
+			at = e.wakeAt[i]
+		}
+		if at != -1 && (next == -1 || at < next) {
+			next = at
+		}
+	}
+	for _, cc := range []*cgroupController{e.cgroups, e.rtBandwidth} {
+		if cc == nil {
+			continue
+		}
+		if at := cc.nextUnthrottle(); at > e.time && (next == -1 || at < next) {
+			next = at
+		}
+	}
+	return next
+}
+
+// Leave the CPU idle until the given time. Processes stuck in the ready
+// queue (for example threads without a kernel thread) keep waiting.
+func (e *engine) idle(until int) {
+	for _, j := range e.queue {
+		e.procs[j].WaitingTime += until - e.time
+	}
+	e.time = until
+}
+
+// Move processes that have arrived or finished their I/O by now into the
+// ready queue
+func (e *engine) admit() {
+	// Processes becoming ready at the same moment queue in tie-breaker order
+	var due []int
+	for i := range e.procs {
+		if e.state[i] == stateBlocked && e.wakeAt[i] != -1 && e.wakeAt[i] <= e.time ||
+			e.state[i] == stateNew && e.procs[i].ArrivalTime <= e.time {
+			due = append(due, i)
+		}
+	}
+	sort.SliceStable(due, func(a, b int) bool {
+		return e.tieBreaker.before(e.procs, due[a], due[b])
+	})
+
+	for _, i := range due {
+		switch {
+		case e.state[i] == stateBlocked:
+			e.wakeAt[i] = -1
+			e.makeReady(i)
+		case e.state[i] == stateNew:
+			e.makeReady(i)
+			// Children forked or I/O issued before the process runs at all
+			e.fork(i)
+			if e.state[i] == stateReady && e.startIO(i) {
+				e.dequeue(i)
+			}
+		}
+	}
+}
+
+// Whether process i is allowed onto the CPU right now
+func (e *engine) canRun(i int) bool {
+	if e.cgroups != nil && !e.cgroups.allows(e.procs[i].Cgroup, e.time) {
+		return false
+	}
+	if e.rtBandwidth != nil && isRealtime(e.procs[i]) && !e.rtBandwidth.allows(rtBandwidthGroup, e.time) {
+		return false
+	}
+	for _, allowed := range e.filters {
+		if !allowed(i) {
+			return false
+		}
+	}
+	return true
+}
+
+// Put a process at the back of the ready queue, or finish it straight away
+// if it has nothing left to do
+func (e *engine) makeReady(i int) {
+	if e.procs[i].RemainingTime <= 0 {
+		e.complete(i)
+		return
+	}
+	if e.linux != nil {
+		e.placeLinux(i)
+	}
+	e.state[i] = stateReady
+	e.readyAt[i] = e.time
+	e.queue = append(e.queue, i)
+}
+
+// Decide which process runs during the next time unit
+func (e *engine) dispatch() {
+	if e.running != -1 {
+		switch {
+		case !e.canRun(e.running):
+			// Even non-preemptive algorithms lose the CPU once the cgroup is
+			// throttled; the process keeps its place at the head of the queue
+			e.state[e.running] = stateReady
+			e.queue = append([]int{e.running}, e.queue...)
+			e.running = -1
+		case e.shouldPreempt():
+			// Preempted process goes to the back of the ready queue, except a
+			// SCHED_FIFO task, which stays at the head of its priority
+			preempted := e.running
+			e.running = -1
+			e.makeReady(preempted)
+			if e.procs[preempted].Policy == schedFIFO && e.linux != nil {
+				e.dequeue(preempted)
+				e.queue = append([]int{preempted}, e.queue...)
+			}
+
+			// Under the preempted-first RR convention it also goes ahead of
+			// whoever became ready at this same instant
+			if e.preemptedFirst && (e.algorithm == "RR" || e.algorithm == "VRR" || e.algorithm == "PriorityRR") {
+				e.dequeue(preempted)
+				pos := len(e.queue)
+				for pos > 0 && e.readyAt[e.queue[pos-1]] == e.time {
+					pos--
+				}
+				e.queue = append(e.queue[:pos], append([]int{preempted}, e.queue[pos:]...)...)
+			}
+		default:
+			return
+		}
+	}
+
+	pos := e.pick()
+	if pos == -1 {
+		return
+	}
+	i := e.queue[pos]
+	e.queue = append(e.queue[:pos], e.queue[pos+1:]...)
+
+	// If this is the first time this process gets CPU, record response time
+	if !e.procs[i].IsStarted {
+		e.procs[i].StartTime = e.time
+		e.procs[i].ResponseTime = e.time - e.procs[i].ArrivalTime
+		e.procs[i].IsStarted = true
+	}
+
+	e.state[i] = stateRunning
+	e.running = i
+	e.sliceUsed = 0
+
+	// A process from the VRR auxiliary queue only gets what was left of
+	// its quantum
+	if e.algorithm == "VRR" && e.vrrLeft[i] > 0 {
+		e.sliceUsed = e.timeQuantum - e.vrrLeft[i]
+		e.vrrLeft[i] = 0
+	}
+}
+
+// Whether the running process should give up the CPU before the next unit
+func (e *engine) shouldPreempt() bool {
+	contender := false
+	for _, j := range e.queue {
+		if e.canRun(j) {
+			contender = true
+			break
+		}
+	}
+	if !contender {
+		if e.sliceUsed >= e.timeQuantum {
+			e.sliceUsed = 0 // Nobody else can run, start a fresh quantum
+		}
+		return false
+	}
+
+	switch e.algorithm {
+	case "Linux":
+		return e.linuxShouldPreempt()
+	case "RR", "VRR", "FairShare":
+		return e.sliceUsed >= e.timeQuantum
+	case "SJF", "LJF":
+		if !e.isPreemptive {
+			return false
+		}
+		for _, j := range e.queue {
+			if e.canRun(j) && e.primaryCompare(j, e.running) < 0 {
+				return true
+			}
+		}
+	case "Priority":
+		if !e.isPreemptive {
+			return false
+		}
+		for _, j := range e.queue {
+			if e.canRun(j) && e.primaryCompare(j, e.running) < 0 {
+				return true
+			}
+		}
+	case "PriorityRR":
+		// A higher band always preempts; the same band takes over once the
+		// quantum is up
+		for _, j := range e.queue {
+			if !e.canRun(j) {
+				continue
+			}
+			c := e.primaryCompare(j, e.running)
+			if c < 0 || c == 0 && e.sliceUsed >= e.timeQuantum {
+				return true
+			}
+		}
+		if e.sliceUsed >= e.timeQuantum {
+			e.sliceUsed = 0 // Nobody in the band is waiting, start a fresh quantum
+		}
+	}
+	return false
+}
+
+// Order of processes a and b under SJF, LJF or Priority, negative if a goes
+// first. Ties go to the tie-breaker; other algorithms, and PriorityRR
+// within a band, keep queue order.
+func (e *engine) keyCompare(a, b int) int {
+	c := e.primaryCompare(a, b)
+	switch {
+	case c != 0:
+		return c
+	case e.algorithm == "SJF", e.algorithm == "LJF", e.algorithm == "Priority":
+		return e.tieBreaker.compare(e.procs[a], a, e.procs[b], b)
+	}
+	return 0
+}
+
+// Order of processes a and b by the algorithm's own key alone. Preemption
+// goes by this, so a process only tied on the key never displaces the one
+// running, whatever the tie-breaker says.
+func (e *engine) primaryCompare(a, b int) int {
+	pa, pb := e.procs[a], e.procs[b]
+	switch e.algorithm {
+	case "SJF":
+		if e.isPreemptive {
+			return pa.RemainingTime - pb.RemainingTime
+		}
+		return pa.BurstTime - pb.BurstTime
+	case "LJF":
+		// Longest Job First, or Longest Remaining Time First when preemptive
+		if e.isPreemptive {
+			return pb.RemainingTime - pa.RemainingTime
+		}
+		return pb.BurstTime - pa.BurstTime
+	case "Priority", "PriorityRR":
+		return priorityRank(pa, e.higherFirst) - priorityRank(pb, e.higherFirst)
+	}
+	return 0
+}
+
+// Position in the ready queue of the process to run next, or -1 if nobody
+// can run. Ties go to the process that has been ready the longest.
+func (e *engine) pick() int {
+	switch e.algorithm {
+	case "FairShare":
+		return e.pickFairShare()
+	case "Linux":
+		return e.pickLinux()
+	case "VRR":
+		// The auxiliary queue of processes back from I/O goes first
+		for pos, j := range e.queue {
+			if e.vrrLeft[j] > 0 && e.canRun(j) {
+				return pos
+			}
+		}
+	}
+
+	best := -1
+	for pos, j := range e.queue {
+		if !e.canRun(j) {
+			continue
+		}
+		if best == -1 {
+			best = pos
+			continue
+		}
+		if e.keyCompare(j, e.queue[best]) < 0 {
+			best = pos
+		}
+	}
+	return best
+}
+
+// Run the current process for one time unit
+func (e *engine) step() {
+	i := e.running
+
+	// Extend the running process's timeline segment or start a new one
+	if n := len(e.timeline); n > 0 && e.timeline[n-1].ProcessID == e.procs[i].ID && e.timeline[n-1].EndTime == e.time {
+		e.timeline[n-1].EndTime++
+	} else {
+		e.timeline = append(e.timeline, TimelineSegment{
+			ProcessID: e.procs[i].ID,
+			StartTime: e.time,
+			EndTime:   e.time + 1,
+		})
+	}
+
+	// Everyone left in the ready queue waits for this unit
+	for _, j := range e.queue {
+		e.procs[j].WaitingTime++
+	}
+
+	if e.shares != nil {
+		e.shares.accrue(e)
+	}
+
+	if e.cgroups != nil {
+		e.cgroups.charge(e.procs[i].Cgroup, e.time)
+	}
+	if e.linux != nil {
+		e.chargeLinux(i)
+	}
+	if e.rtBandwidth != nil && isRealtime(e.procs[i]) {
+		e.rtBandwidth.charge(rtBandwidthGroup, e.time)
+	}
+
+	e.time++
+	e.procs[i].RemainingTime--
+	e.sliceUsed++
+
+	e.fork(i)
+
+	switch {
+	case e.waitingOn[i] > 0:
+		// Parent blocks until the children it waits for have exited
+		e.state[i] = stateBlocked
+		e.running = -1
+	case e.startIO(i):
+		// Process blocks until its I/O completes
+		e.running = -1
+		if e.algorithm == "VRR" {
+			e.vrrLeft[i] = e.timeQuantum - e.sliceUsed
+		}
+	case e.procs[i].RemainingTime == 0:
+		e.running = -1
+		e.complete(i)
+	}
+}
+
+// Fire any spawns of process i whose offset into its burst has been reached
+func (e *engine) fork(i int) {
+	executed := e.procs[i].BurstTime - e.procs[i].RemainingTime
+	for k, s := range e.procs[i].Spawns {
+		if e.forked[i][k] || s.Offset > executed {
+			continue
+		}
+		e.forked[i][k] = true
+
+		child := inheritFrom(e.procs[i], s.Child, e.inheritPolicy)
+		if child.ID == "" {
+			child.ID = defaultChildID(e.procs[i].ID, k)
+		}
+		child.ParentID = e.procs[i].ID
+		if child.Cgroup == "" {
+			child.Cgroup = e.procs[i].Cgroup // Children start in their parent's cgroup
+		}
+		if child.User == "" {
+			child.User = e.procs[i].User
+		}
+		child.ArrivalTime = e.time
+		child.RemainingTime = child.BurstTime
+		child.IsStarted = false
+
+		c := e.add(child, i, s.Wait)
+		if s.Wait {
+			e.waitingOn[i]++
+		}
+		e.makeReady(c)
+		e.fork(c)
+	}
+
+	// A parent that was only sitting in the ready queue blocks right away
+	if e.waitingOn[i] > 0 && e.state[i] == stateReady {
+		e.dequeue(i)
+		e.state[i] = stateBlocked
+	}
+}
+
+// Start the next I/O of process i if it has reached its offset, blocking the
+// process until the I/O completes
+func (e *engine) startIO(i int) bool {
+	executed := e.procs[i].BurstTime - e.procs[i].RemainingTime
+	for k, io := range e.procs[i].IOBursts {
+		if e.ioStarted[i][k] || io.Offset > executed {
+			continue
+		}
+		e.ioStarted[i][k] = true
+		if io.Duration <= 0 {
+			continue
+		}
+		e.state[i] = stateBlocked
+		e.wakeAt[i] = e.time + io.Duration
+		return true
+	}
+	return false
+}
+
+// Remove a process from the ready queue
+func (e *engine) dequeue(i int) {
+	for pos, j := range e.queue {
+		if j == i {
+			e.queue = append(e.queue[:pos], e.queue[pos+1:]...)
+			return
+		}
+	}
+}
+
+// Mark a process as finished and wake its parent if it was waiting on it
+func (e *engine) complete(i int) {
+	// Spawns at or past the end of the burst fire as the process exits
+	e.fork(i)
+
+	if e.waitingOn[i] > 0 {
+		// Burst is used up but the process is still in wait()
+		e.state[i] = stateBlocked
+		return
+	}
+
+	e.state[i] = stateDone
+	e.procs[i].RemainingTime = 0
+	e.procs[i].CompletionTime = e.time
+	e.procs[i].TurnaroundTime = e.procs[i].CompletionTime - e.procs[i].ArrivalTime
+
+	parent := e.parent[i]
+	if parent == -1 || !e.waitedBy[i] {
+		return
+	}
+	e.waitingOn[parent]--
+	if e.waitingOn[parent] == 0 && e.state[parent] == stateBlocked {
+		e.makeReady(parent)
+	}
+}
+
+func (e *engine) response() SimulationResponse {
+	response := SimulationResponse{
+		Processes: e.procs,
+		Timeline:  e.timeline,
+	}
+
+	// Show throttled intervals alongside the processes that ran
+	if e.cgroups != nil {
+		response.Timeline = append(response.Timeline, e.cgroups.segments...)
+		response.CgroupStats = e.cgroups.stats
+	}
+	if e.rtBandwidth != nil {
+		response.Timeline = append(response.Timeline, e.rtBandwidth.segments...)
+		response.RTThrottle = &e.rtBandwidth.stats[0]
+	}
+	sort.SliceStable(response.Timeline, func(a, b int) bool {
+		return response.Timeline[a].StartTime < response.Timeline[b].StartTime
+	})
+	if e.shares != nil {
+		response.ShareReport = shareReport(e.shares, e.procs)
+	}
+	if e.linux != nil {
+		response.vruntimes = e.linux.placed
+	}
+	return response
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"runtime"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Monte Carlo experiments: every algorithm config run on many independent
+// workloads drawn from one spec, so a comparison rests on more than one
+// hand-picked process set. Each metric gets a mean with a 95% confidence
+// interval, and every pair of runs gets a paired t-test on the per-workload
+// differences, which cancels out how hard each workload happened to be.
+
+// Bounds on an experiment: replications, and processes simulated over all
+// replications and runs
+const (
+	maxReplications   = 1000
+	maxExperimentWork = 2000000
+)
+
+// Confidence level of the intervals, and the significance level of the
+// paired tests
+const (
+	confidenceLevel   = 0.95
+	significanceLevel = 1 - confidenceLevel
+)
+
+// ExperimentRequest is a comparison whose processes are generated rather
+// than given. Replication k uses the workload seed plus k. The workload's
+// time unit and resolution are the experiment's.
+type ExperimentRequest struct {
+	CompareRequest
+	Replications int `json:"replications"`
+
+	// Decoded as sent, since distribution parameters are not time values
+	// to scale
+	Workload GenerateRequest `json:"workload"`
+}
+
+type ExperimentResponse struct {
+	Seed         int64              `json:"seed"`
+	Replications int                `json:"replications"`
+	Confidence   float64            `json:"confidence"`
+	Runs         []ExperimentRun    `json:"runs"`
+	Comparisons  []PairedComparison `json:"comparisons"`
+}
+
+// ExperimentRun is one algorithm config's estimates over all replications
+type ExperimentRun struct {
+	Name    string           `json:"name"`
+	Config  AlgorithmConfig  `json:"config"`
+	Metrics []MetricEstimate `json:"metrics"`
+}
+
+// MetricEstimate is a metric's sample mean over the replications with its
+// confidence interval. Time metrics are in the time unit.
+type MetricEstimate struct {
+	Metric       string  `json:"metric"`
+	Better       string  `json:"better"` // "lower" or "higher"
+	SampleMean   float64 `json:"sampleMean"`
+	SampleStdDev float64 `json:"sampleStdDev"`
+	HalfWidth    float64 `json:"halfWidth"`
+	Low          float64 `json:"ciLow"`
+	High         float64 `json:"ciHigh"`
+}
+
+// PairedComparison tests one metric of run A against run B on the same
+// workloads. Differences are A minus B, one per replication. TStatistic is
+// left out when every difference is the same, and the p-value is then 1 or
+// 0 depending on whether they are all zero.
+type PairedComparison struct {
+	A              string    `json:"a"`
+	B              string    `json:"b"`
+	Metric         string    `json:"metric"`
+	MeanDifference float64   `json:"meanDifference"`
+	Low            float64   `json:"ciLow"`
+	High           float64   `json:"ciHigh"`
+	TStatistic     *float64  `json:"tStatistic,omitempty"`
+	PValue         float64   `json:"pValue"`
+	Significant    bool      `json:"significant"`
+	Winner         string    `json:"winner,omitempty"` // Set when significant
+	Differences    []float64 `json:"differences"`
+}
+
+func handleExperiment(c *gin.Context) {
+	var req ExperimentRequest
+	scale, raw, ok := decodeBody(c, &req)
+	if !ok {
+		return
+	}
+	if _, ok := raw["workload"]; !ok {
+		rejectRequest(c, []FieldError{fieldError("workload", codeRequired, "A workload spec is required")})
+		return
+	}
+	req.Workload.TimeUnit = req.TimeUnit
+	req.Workload.TimeResolution = req.TimeResolution
+	if req.Workload.Seed == nil {
+		seed := rand.Int64N(1 << 53)
+		req.Workload.Seed = &seed
+	}
+	if errs := validateExperiment(req, raw, scale); len(errs) > 0 {
+		rejectRequest(c, errs)
+		return
+	}
+
+	respond(c, experiment(req, scale), req.TimeResolution)
+}
+
+// Check the experiment's own options and workload, then the runs as a
+// comparison on every replication's workload. Errors the workloads share
+// are reported once.
+func validateExperiment(req ExperimentRequest, raw map[string]interface{}, scale int64) []FieldError {
+	var errs []FieldError
+	if len(req.Processes) > 0 {
+		errs = append(errs, fieldError("processes", codeUnsupported, "Processes are generated from the workload spec"))
+	}
+	switch {
+	case req.Replications < 2:
+		errs = append(errs, fieldError("replications", codeOutOfRange, "At least 2 replications are needed for a confidence interval"))
+	case req.Replications > maxReplications:
+		errs = append(errs, fieldError("replications", codeOutOfRange, fmt.Sprintf("At most %d replications can be run", maxReplications)))
+	case req.Replications*req.Workload.Count*len(req.Algorithms) > maxExperimentWork:
+		errs = append(errs, fieldError("replications", codeOutOfRange,
+			fmt.Sprintf("Replications times processes times algorithms cannot exceed %d", maxExperimentWork)))
+	}
+	// Generated processes have no priority unless the spec draws one
+	if req.Workload.Priorities == nil {
+		for _, cfg := range req.Algorithms {
+			if isPriorityAlgorithm(cfg.Algorithm) {
+				errs = append(errs, fieldError("workload.priorities", codeRequired, "Priorities are required for priority scheduling"))
+				break
+			}
+		}
+	}
+	// Once the options are wrong the replication count may be too, so only
+	// the first workload is checked
+	replications := req.Replications
+	if len(errs) > 0 {
+		replications = 1
+	}
+	workloadErrs := validateGenerate(req.Workload)
+	for _, e := range workloadErrs {
+		e.Field = "workload." + e.Field
+		errs = append(errs, e)
+	}
+	if len(workloadErrs) > 0 {
+		return errs
+	}
+
+	// Each replication draws its own workload, and any one of them may be
+	// the one that breaks a limit
+	seen := make(map[FieldError]bool)
+	for rep := 0; rep < replications; rep++ {
+		compareReq := req.CompareRequest
+		compareReq.Processes = generateWorkload(req.Workload, scale, *req.Workload.Seed+int64(rep))
+		for _, e := range validateCompare(compareReq, raw) {
+			if !seen[e] {
+				seen[e] = true
+				errs = append(errs, e)
+			}
+		}
+	}
+	return errs
+}
+
+// Run every config on every replication's workload and summarize
+func experiment(req ExperimentRequest, scale int64) ExperimentResponse {
+	k := req.Replications
+	seed := *req.Workload.Seed
+
+	// values[run][metric][replication]
+	values := make([][][]float64, len(req.Algorithms))
+	for r := range values {
+		values[r] = make([][]float64, len(compareMetrics))
+		for m := range values[r] {
+			values[r][m] = make([]float64, k)
+		}
+	}
+
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, runtime.NumCPU())
+	for rep := 0; rep < k; rep++ {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(rep int) {
+			defer wg.Done()
+			base := req.SimulationRequest
+			base.Processes = generateWorkload(req.Workload, scale, seed+int64(rep))
+			for r, cfg := range req.Algorithms {
+				result := simulateValidated(runRequest(base, cfg), scale)
+				for m, metric := range compareMetrics {
+					values[r][m][rep] = metric.report(result, scale)
+				}
+			}
+			<-slots
+		}(rep)
+	}
+	wg.Wait()
+
+	response := ExperimentResponse{Seed: seed, Replications: k, Confidence: confidenceLevel}
+	names := runNames(req.Algorithms, scale)
+	t := studentTQuantile(significanceLevel, float64(k-1))
+	for r, cfg := range req.Algorithms {
+		run := ExperimentRun{Name: names[r], Config: cfg}
+		for m, metric := range compareMetrics {
+			mean, sd := sampleStats(values[r][m])
+			half := t * sd / math.Sqrt(float64(k))
+			run.Metrics = append(run.Metrics, MetricEstimate{
+				Metric: metric.name, Better: better(metric), SampleMean: mean, SampleStdDev: sd,
+				HalfWidth: half, Low: mean - half, High: mean + half,
+			})
+		}
+		response.Runs = append(response.Runs, run)
+	}
+
+	for a := range req.Algorithms {
+		for b := a + 1; b < len(req.Algorithms); b++ {
+			for m, metric := range compareMetrics {
+				diffs := make([]float64, k)
+				for rep := range diffs {
+					diffs[rep] = values[a][m][rep] - values[b][m][rep]
+				}
+				response.Comparisons = append(response.Comparisons, pairedTest(names[a], names[b], metric, diffs, t))
+			}
+		}
+	}
+	return response
+}
+
+// Sample mean and standard deviation (with n-1) of at least two values
+func sampleStats(xs []float64) (float64, float64) {
+	mean := 0.0
+	for _, x := range xs {
+		mean += x
+	}
+	mean /= float64(len(xs))
+	squares := 0.0
+	for _, x := range xs {
+		squares += (x - mean) * (x - mean)
+	}
+	return mean, math.Sqrt(squares / float64(len(xs)-1))
+}
+
+// Paired t-test of run a against run b on the differences a - b. t is the
+// critical value for the confidence interval.
+func pairedTest(a, b string, metric compareMetric, diffs []float64, t float64) PairedComparison {
+	k := float64(len(diffs))
+	mean, sd := sampleStats(diffs)
+	half := t * sd / math.Sqrt(k)
+	pc := PairedComparison{
+		A: a, B: b, Metric: metric.name,
+		MeanDifference: mean, Low: mean - half, High: mean + half,
+		Differences: diffs,
+	}
+	switch {
+	case sd > 0:
+		stat := mean / (sd / math.Sqrt(k))
+		pc.TStatistic = &stat
+		pc.PValue = studentTPValue(stat, k-1)
+	case mean == 0:
+		pc.PValue = 1
+	}
+	pc.Significant = pc.PValue < significanceLevel
+	if pc.Significant {
+		pc.Winner = b
+		if mean > 0 == metric.higherBetter {
+			pc.Winner = a
+		}
+	}
+	return pc
+}
@@ -0,0 +1,27 @@
+package main
+
+import "testing"
+
+// Every replication's workload is checked, not just the first: with seed
+// 1 the first two draw about 1.2M and 4.2M ticks of work, the third 6.5M,
+// past what VRR may step through
+func TestExperimentValidatesEveryWorkload(t *testing.T) {
+	for _, tc := range []struct {
+		replications int
+		rejected     bool
+	}{{2, false}, {3, true}} {
+		seed := int64(1)
+		req := ExperimentRequest{Replications: tc.replications}
+		req.Algorithms = []AlgorithmConfig{{Algorithm: "FCFS"}, {Algorithm: "VRR", TimeQuantum: 1}}
+		req.Workload = GenerateRequest{Count: 2, Seed: &seed,
+			Arrivals: DistributionSpec{Kind: "poisson", Rate: 1},
+			Bursts:   DistributionSpec{Kind: "uniform", Min: 1, Max: 4000000}}
+		errs := validateExperiment(req, map[string]interface{}{}, 1)
+		if rejected := len(errs) > 0; rejected != tc.rejected {
+			t.Errorf("%d replications: errors %v, want rejected %v", tc.replications, errs, tc.rejected)
+		}
+		if len(errs) > 1 {
+			t.Errorf("%d replications: %d errors, want the shared one once", tc.replications, len(errs))
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+// Fairness measures how evenly a run treated its processes. A process's
+// normalized CPU share is its burst over its turnaround time: the fraction
+// of its time in the system it spent running. Slowdown is the inverse.
+type Fairness struct {
+	// Jain's fairness index over the normalized shares, from 1/n (one
+	// process got everything) to 1 (all got the same share)
+	JainIndex float64 `json:"jainIndex"`
+
+	// Largest gap between a process's normalized share and the mean share,
+	// relative to the mean
+	ShareError float64 `json:"shareError"`
+
+	MaxSlowdown  float64 `json:"maxSlowdown"`
+	MeanSlowdown float64 `json:"meanSlowdown"`
+
+	// Longest stretch any process spent ready but not running
+	MaxReadyWait int `json:"maxReadyWait" time:"ticks"`
+
+	// Processes whose longest ready wait exceeded the request's
+	// StarvationThreshold, if it set one
+	StarvationThreshold int      `json:"starvationThreshold,omitempty" time:"ticks"`
+	Starved             []string `json:"starved,omitempty"`
+}
+
+// Work out the fairness of a run from its processes and their state
+// intervals, filling in each process's longest ready wait and starvation
+// flag. A threshold of 0 flags nobody.
+func fairness(processes []Process, states []entityStates, threshold int) *Fairness {
+	f := &Fairness{StarvationThreshold: threshold}
+
+	// Normalized shares and slowdowns
+	var shares []float64
+	var slowdownTotal float64
+	for _, p := range processes {
+		if p.Abandoned || p.BurstTime <= 0 || p.TurnaroundTime <= 0 {
+			continue
+		}
+		share := float64(p.BurstTime) / float64(p.TurnaroundTime)
+		shares = append(shares, share)
+		slowdown := 1 / share
+		slowdownTotal += slowdown
+		if slowdown > f.MaxSlowdown {
+			f.MaxSlowdown = slowdown
+		}
+	}
+	if len(shares) > 0 {
+		var sum, squares float64
+		for _, x := range shares {
+			sum += x
+			squares += x * x
+		}
+		n := float64(len(shares))
+		f.JainIndex = sum * sum / (n * squares)
+		f.MeanSlowdown = slowdownTotal / n
+		mean := sum / n
+		for _, x := range shares {
+			gap := (x - mean) / mean
+			if gap < 0 {
+				gap = -gap
+			}
+			if gap > f.ShareError {
+				f.ShareError = gap
+			}
+		}
+	}
+
+	// Longest ready waits, per schedulable entity and then per process
+	for _, st := range states {
+		p := &processes[st.owner]
+		for _, iv := range st.ready {
+			if wait := iv.end - iv.start; wait > p.LongestReadyWait {
+				p.LongestReadyWait = wait
+			}
+		}
+	}
+	for i := range processes {
+		p := &processes[i]
+		if p.LongestReadyWait > f.MaxReadyWait {
+			f.MaxReadyWait = p.LongestReadyWait
+		}
+		if threshold > 0 && p.LongestReadyWait > threshold {
+			p.Starved = true
+			f.Starved = append(f.Starved, p.ID)
+		}
+	}
+	return f
+}
@@ -0,0 +1,235 @@
+package main
+
+// ShareGroup and ShareUser weight the two upper levels of the fair-share
+// hierarchy. A group's users split the group's share by their own weights,
+// and a user's processes take turns in round-robin.
+type ShareGroup struct {
+	ID     string `json:"id"`
+	Shares int    `json:"shares"`
+}
+
+type ShareUser struct {
+	ID     string `json:"id"`
+	Group  string `json:"group"`
+	Shares int    `json:"shares"`
+}
+
+// ShareReport compares the CPU share an entity was entitled to with what it
+// actually got over the whole simulation
+type ShareReport struct {
+	Level         string  `json:"level"` // "group", "user" or "process"
+	ID            string  `json:"id"`
+	Parent        string  `json:"parent,omitempty"`
+	TargetShare   float64 `json:"targetShare"`
+	AchievedShare float64 `json:"achievedShare"`
+}
+
+// Largest weight a group or user may have, the same cap as cgroup
+// cpu.shares. Together with the step engine's tick limit it keeps the
+// usage*shares products in pickFairShare well inside an int.
+const maxShares = 262144
+
+// shareTree holds the group/user hierarchy for the FairShare algorithm.
+// Users and groups that are referenced but not declared get one share.
+type shareTree struct {
+	groupShares map[string]int
+	userShares  map[string]int
+	userGroup   map[string]string
+	groups      []string // In order of first appearance, for reporting
+	users       []string
+
+	entitled []float64 // CPU time each process was entitled to, indexed like engine.procs
+}
+
+func newShareTree(groups []ShareGroup, users []ShareUser, processes []Process) *shareTree {
+	t := &shareTree{
+		groupShares: make(map[string]int),
+		userShares:  make(map[string]int),
+		userGroup:   make(map[string]string),
+	}
+	for _, g := range groups {
+		t.groupShares[g.ID] = g.Shares
+	}
+	for _, u := range users {
+		t.userShares[u.ID] = u.Shares
+		t.userGroup[u.ID] = u.Group
+	}
+
+	// Only groups and users that actually own processes take part
+	seenGroup := make(map[string]bool)
+	seenUser := make(map[string]bool)
+	for _, p := range processes {
+		if !seenUser[p.User] {
+			seenUser[p.User] = true
+			t.users = append(t.users, p.User)
+		}
+		g := t.userGroup[p.User]
+		if !seenGroup[g] {
+			seenGroup[g] = true
+			t.groups = append(t.groups, g)
+		}
+	}
+
+	for _, g := range t.groups {
+		if t.groupShares[g] <= 0 {
+			t.groupShares[g] = 1
+		}
+	}
+	for _, u := range t.users {
+		if t.userShares[u] <= 0 {
+			t.userShares[u] = 1
+		}
+	}
+	return t
+}
+
+// Position in the ready queue of the process to run next under fair share:
+// the group furthest below its share, then the user in that group furthest
+// below theirs, then whichever of the user's processes has waited longest.
+// Usage counts everything since the start of the run and is never decayed,
+// so a user that sat idle early on is owed the time once it turns up.
+func (e *engine) pickFairShare() int {
+	t := e.shares
+
+	// CPU time used so far by each user and group
+	userUsage := make(map[string]int)
+	groupUsage := make(map[string]int)
+	for _, p := range e.procs {
+		used := p.BurstTime - p.RemainingTime
+		userUsage[p.User] += used
+		groupUsage[t.userGroup[p.User]] += used
+	}
+
+	// Compare usage/shares ratios without dividing
+	below := func(usageA, sharesA, usageB, sharesB int) int {
+		a, b := usageA*sharesB, usageB*sharesA
+		switch {
+		case a < b:
+			return -1
+		case a > b:
+			return 1
+		}
+		return 0
+	}
+
+	best := -1
+	for pos, j := range e.queue {
+		if !e.canRun(j) {
+			continue
+		}
+		if best == -1 {
+			best = pos
+			continue
+		}
+
+		p, b := e.procs[j], e.procs[e.queue[best]]
+		pg, bg := t.userGroup[p.User], t.userGroup[b.User]
+		cmp := below(groupUsage[pg], t.groupShares[pg], groupUsage[bg], t.groupShares[bg])
+		if cmp == 0 {
+			cmp = below(userUsage[p.User], t.userShares[p.User], userUsage[b.User], t.userShares[b.User])
+		}
+		if cmp < 0 {
+			best = pos
+		}
+	}
+	return best
+}
+
+// Split one time unit of CPU among the backlogged groups, users and
+// processes by their weights, as an ideal fair-share scheduler would. Only
+// entities with a process ready or running take part, so an idle user's
+// share goes to its siblings.
+func (t *shareTree) accrue(e *engine) {
+	for len(t.entitled) < len(e.procs) {
+		t.entitled = append(t.entitled, 0)
+	}
+
+	groupShares := 0
+	userShares := make(map[string]int) // Per group, over backlogged users
+	userProcs := make(map[string]int)  // Per user, backlogged processes
+	for i, p := range e.procs {
+		if e.state[i] != stateReady && e.state[i] != stateRunning {
+			continue
+		}
+		if userProcs[p.User] == 0 {
+			g := t.userGroup[p.User]
+			if userShares[g] == 0 {
+				groupShares += t.groupShares[g]
+			}
+			userShares[g] += t.userShares[p.User]
+		}
+		userProcs[p.User]++
+	}
+
+	for i, p := range e.procs {
+		if e.state[i] != stateReady && e.state[i] != stateRunning {
+			continue
+		}
+		g := t.userGroup[p.User]
+		share := float64(t.groupShares[g]) / float64(groupShares)
+		share *= float64(t.userShares[p.User]) / float64(userShares[g])
+		share /= float64(userProcs[p.User])
+		t.entitled[i] += share
+	}
+}
+
+// Target versus achieved CPU share at each level of the hierarchy, as
+// fractions of the total busy time. The target is what an ideal fair-share
+// scheduler would have given each entity while it was competing for the CPU.
+func shareReport(t *shareTree, procs []Process) []ShareReport {
+	var busy int
+	for _, p := range procs {
+		busy += p.BurstTime - p.RemainingTime
+	}
+	if busy == 0 {
+		return nil
+	}
+
+	groupTarget := make(map[string]float64)
+	groupUsage := make(map[string]int)
+	userTarget := make(map[string]float64)
+	userUsage := make(map[string]int)
+	for i, p := range procs {
+		used := p.BurstTime - p.RemainingTime
+		g := t.userGroup[p.User]
+		if i < len(t.entitled) {
+			groupTarget[g] += t.entitled[i]
+			userTarget[p.User] += t.entitled[i]
+		}
+		groupUsage[g] += used
+		userUsage[p.User] += used
+	}
+
+	var report []ShareReport
+	for _, g := range t.groups {
+		report = append(report, ShareReport{
+			Level:         "group",
+			ID:            g,
+			TargetShare:   groupTarget[g] / float64(busy),
+			AchievedShare: float64(groupUsage[g]) / float64(busy),
+		})
+	}
+	for _, u := range t.users {
+		report = append(report, ShareReport{
+			Level:         "user",
+			ID:            u,
+			Parent:        t.userGroup[u],
+			TargetShare:   userTarget[u] / float64(busy),
+			AchievedShare: float64(userUsage[u]) / float64(busy),
+		})
+	}
+	for i, p := range procs {
+		var target float64
+		if i < len(t.entitled) {
+			target = t.entitled[i]
+		}
+		report = append(report, ShareReport{
+			Level:         "process",
+			ID:            p.ID,
+			Parent:        p.User,
+			TargetShare:   target / float64(busy),
+			AchievedShare: float64(p.BurstTime-p.RemainingTime) / float64(busy),
+		})
+	}
+	return report
+}
@@ -0,0 +1,40 @@
+package main
+
+import "testing"
+
+func TestFairShareConverges(t *testing.T) {
+	runScheduleCases(t, []scheduleCase{
+		{
+			// Group a has 3 shares to b's 1, so while both are backlogged
+			// A gets 3 ticks for every 1 of B's
+			name:     "group ratio",
+			body:     `{"algorithm": "FairShare", "shareGroups": [{"id": "a", "shares": 3}, {"id": "b", "shares": 1}], "shareUsers": [{"id": "u", "group": "a"}, {"id": "v", "group": "b"}], "processes": [{"id": "A", "burstTime": 30, "user": "u"}, {"id": "B", "burstTime": 30, "user": "v"}]}`,
+			timeline: "A 0-1, B 1-2, A 2-4, B 4-5, A 5-8, B 8-9, A 9-12, B 12-13, A 13-16, B 16-17, A 17-20, B 20-21, A 21-24, B 24-25, A 25-28, B 28-29, A 29-32, B 32-33, A 33-36, B 36-37, A 37-40, B 40-60",
+			times:    "A 10/40, B 30/60",
+			check: func(t *testing.T, r SimulationResponse) {
+				used := map[string]int{}
+				for _, seg := range r.Timeline {
+					if seg.StartTime >= 40 {
+						break
+					}
+					used[seg.ProcessID] += seg.EndTime - seg.StartTime
+					if d := used["A"] - 3*used["B"]; d < -3 || d > 3 {
+						t.Errorf("at %d A has run %d and B %d, not 3:1", seg.EndTime, used["A"], used["B"])
+					}
+				}
+			},
+		},
+	})
+}
+
+func TestShareBounds(t *testing.T) {
+	req := SimulationRequest{
+		ShareGroups: []ShareGroup{{ID: "a", Shares: maxShares}, {ID: "b", Shares: maxShares + 1}},
+		ShareUsers:  []ShareUser{{ID: "u", Shares: -1}},
+	}
+	errs := validateShares(req)
+	if len(errs) != 2 || errs[0].Field != "shareGroups[1].shares" || errs[0].Code != codeOutOfRange ||
+		errs[1].Field != "shareUsers[0].shares" || errs[1].Code != codeNegative {
+		t.Errorf("errors %v, want shareGroups[1] out of range and shareUsers[0] negative", errs)
+	}
+}
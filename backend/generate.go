@@ -0,0 +1,420 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Synthetic workloads: process sets drawn from statistical distributions,
+// so large or realistic workloads need not be entered one row at a time.
+// Distribution parameters are real numbers in the request's time unit, and
+// the times drawn are rounded to whole ticks. The same seed always gives
+// the same processes.
+
+// Most processes one request may generate
+const maxGeneratedProcesses = 100000
+
+// Largest time generated, in ticks. Heavy tails can draw values too large
+// for the simulators' clocks, so draws are capped here.
+const maxGeneratedTicks = 1 << 40
+
+// Largest priority, either way from zero, a spec may give. Up to 2^53 a
+// float holds every whole number exactly, so draws never round to a value
+// outside the range, and the widest uniform range still fits an int64.
+const maxGeneratedPriority = 1 << 53
+
+type GenerateRequest struct {
+	Count int `json:"count"`
+
+	// Seed for the random draws. Left out, one is picked at random and
+	// reported in the response, so the workload can be made again.
+	Seed *int64 `json:"seed,omitempty"`
+
+	TimeUnit       string `json:"timeUnit,omitempty"`
+	TimeResolution int    `json:"timeResolution,omitempty"`
+
+	// Gaps between arrivals; the first process arrives at time 0
+	Arrivals DistributionSpec `json:"arrivals"`
+	Bursts   DistributionSpec `json:"bursts"`
+
+	// Priorities, left unset when nil
+	Priorities *DistributionSpec `json:"priorities,omitempty"`
+}
+
+// DistributionSpec is how one quantity is drawn. Which fields apply depends on
+// the kind:
+//
+//	poisson      Rate arrivals per time unit, as exponential gaps
+//	exponential  Mean
+//	uniform      Min to Max; whole numbers, both included, for priorities
+//	mmpp         Markov-modulated Poisson: arrivals at Rates[i] while in
+//	             state i, leaving it at SwitchRates[i] for the next state
+//	bimodal      One of two Modes, picked with Weights (equal by default)
+//	pareto       Heavy tail from Scale up with index Shape, capped at Max if set
+//	lognormal    Mu and Sigma of the underlying normal
+//	discrete     One of Values, picked with Weights (equal by default)
+//	trace        Values in order, starting over when they run out
+//
+// Its fields are in the time unit as sent, not ticks, so none is tagged as
+// a time.
+type DistributionSpec struct {
+	Kind string `json:"distribution"`
+
+	Rate  float64 `json:"rate,omitempty"`
+	Mean  float64 `json:"mean,omitempty"`
+	Min   float64 `json:"min,omitempty"`
+	Max   float64 `json:"max,omitempty"`
+	Shape float64 `json:"shape,omitempty"`
+	Scale float64 `json:"scale,omitempty"`
+	Mu    float64 `json:"mu,omitempty"`
+	Sigma float64 `json:"sigma,omitempty"`
+
+	Rates       []float64          `json:"rates,omitempty"`
+	SwitchRates []float64          `json:"switchRates,omitempty"`
+	Modes       []DistributionSpec `json:"modes,omitempty"`
+	Weights     []float64          `json:"weights,omitempty"`
+	Values      []float64          `json:"values,omitempty"`
+}
+
+// Distribution kinds each quantity can be drawn from
+var (
+	arrivalKinds  = []string{"poisson", "uniform", "mmpp", "trace"}
+	burstKinds    = []string{"exponential", "uniform", "bimodal", "pareto", "lognormal", "trace"}
+	modeKinds     = []string{"exponential", "uniform", "pareto", "lognormal"}
+	priorityKinds = []string{"uniform", "discrete", "trace"}
+)
+
+// GenerateResponse can be posted to /simulate as it is, once an algorithm
+// is added
+type GenerateResponse struct {
+	Seed           int64              `json:"seed"`
+	TimeUnit       string             `json:"timeUnit,omitempty"`
+	TimeResolution int                `json:"timeResolution,omitempty"`
+	Processes      []GeneratedProcess `json:"processes"`
+}
+
+// GeneratedProcess is a Process with only the inputs a generator sets
+type GeneratedProcess struct {
+	ID          string `json:"id"`
+	ArrivalTime int    `json:"arrivalTime" time:"ticks"`
+	BurstTime   int    `json:"burstTime" time:"ticks"`
+	Priority    int    `json:"priority,omitempty"`
+}
+
+func handleGenerate(c *gin.Context) {
+	var req GenerateRequest
+	scale, _, ok := decodeBody(c, &req)
+	if !ok {
+		return
+	}
+	if errs := validateGenerate(req); len(errs) > 0 {
+		rejectRequest(c, errs)
+		return
+	}
+
+	// Random seeds fit in 53 bits so they survive a trip through a
+	// JavaScript number
+	seed := rand.Int64N(1 << 53)
+	if req.Seed != nil {
+		seed = *req.Seed
+	}
+	response := GenerateResponse{
+		Seed:           seed,
+		TimeUnit:       req.TimeUnit,
+		TimeResolution: req.TimeResolution,
+		Processes:      generatedProcesses(generateWorkload(req, scale, seed)),
+	}
+	respond(c, response, req.TimeResolution)
+}
+
+// The inputs of processes a generator made
+func generatedProcesses(processes []Process) []GeneratedProcess {
+	out := make([]GeneratedProcess, len(processes))
+	for i, p := range processes {
+		out[i] = GeneratedProcess{ID: p.ID, ArrivalTime: p.ArrivalTime, BurstTime: p.BurstTime, Priority: p.Priority}
+	}
+	return out
+}
+
+func validateGenerate(req GenerateRequest) []FieldError {
+	var errs []FieldError
+	if req.Count <= 0 {
+		errs = append(errs, fieldError("count", codeNotPositive, "Count must be positive"))
+	} else if req.Count > maxGeneratedProcesses {
+		errs = append(errs, fieldError("count", codeOutOfRange, fmt.Sprintf("At most %d processes can be generated at once", maxGeneratedProcesses)))
+	}
+	if !isValidTimeUnit(req.TimeUnit) {
+		errs = append(errs, fieldError("timeUnit", codeUnknown, "Unknown time unit"))
+	}
+	errs = append(errs, validateDistribution("arrivals", req.Arrivals, arrivalKinds, false)...)
+	errs = append(errs, validateDistribution("bursts", req.Bursts, burstKinds, false)...)
+	if req.Priorities != nil {
+		errs = append(errs, validateDistribution("priorities", *req.Priorities, priorityKinds, true)...)
+	}
+	return errs
+}
+
+// Check a distribution at path against the kinds allowed there. Priorities
+// are whole and may be negative; times are neither.
+func validateDistribution(path string, d DistributionSpec, kinds []string, whole bool) []FieldError {
+	var errs []FieldError
+	add := func(field, code, message string) {
+		errs = append(errs, fieldError(path+"."+field, code, message))
+	}
+	positive := func(field, name string, v float64) {
+		if v <= 0 {
+			add(field, codeNotPositive, name+" must be positive")
+		}
+	}
+	// A list of drawn values, such as trace or discrete values
+	checkValues := func(field string, values []float64) {
+		if len(values) == 0 {
+			add(field, codeRequired, "At least one value is required")
+		}
+		for i, v := range values {
+			item := fmt.Sprintf("%s[%d]", field, i)
+			if whole && v != math.Trunc(v) {
+				add(item, codeInvalidType, "Priorities must be whole numbers")
+			} else if whole && math.Abs(v) > maxGeneratedPriority {
+				add(item, codeOutOfRange, "Priorities must be between -2^53 and 2^53")
+			}
+			if !whole && v < 0 {
+				add(item, codeNegative, "Values cannot be negative")
+			}
+		}
+	}
+	// Weights for n choices; left out, the choices are equally likely
+	checkWeights := func(n int) {
+		if len(d.Weights) == 0 {
+			return
+		}
+		if len(d.Weights) != n {
+			add("weights", codeOutOfRange, fmt.Sprintf("Give %d weights, one per choice", n))
+			return
+		}
+		total := 0.0
+		for i, w := range d.Weights {
+			if w < 0 {
+				add(fmt.Sprintf("weights[%d]", i), codeNegative, "Weights cannot be negative")
+			}
+			total += w
+		}
+		if total <= 0 {
+			add("weights", codeNotPositive, "At least one weight must be positive")
+		}
+	}
+
+	known := false
+	for _, k := range kinds {
+		known = known || k == d.Kind
+	}
+	if !known {
+		if d.Kind == "" {
+			add("distribution", codeRequired, "Distribution is required: one of "+strings.Join(kinds, ", "))
+		} else {
+			add("distribution", codeUnsupported, "Distribution must be one of "+strings.Join(kinds, ", "))
+		}
+		return errs
+	}
+
+	switch d.Kind {
+	case "poisson":
+		positive("rate", "Rate", d.Rate)
+	case "exponential":
+		positive("mean", "Mean", d.Mean)
+	case "uniform":
+		if !whole && d.Min < 0 {
+			add("min", codeNegative, "Min cannot be negative")
+		}
+		if whole && (d.Min != math.Trunc(d.Min) || d.Max != math.Trunc(d.Max)) {
+			add("min", codeInvalidType, "Priorities must be whole numbers")
+		}
+		if whole && math.Abs(d.Min) > maxGeneratedPriority {
+			add("min", codeOutOfRange, "Priorities must be between -2^53 and 2^53")
+		}
+		if whole && math.Abs(d.Max) > maxGeneratedPriority {
+			add("max", codeOutOfRange, "Priorities must be between -2^53 and 2^53")
+		}
+		if d.Max < d.Min {
+			add("max", codeOutOfRange, "Max cannot be less than min")
+		}
+	case "mmpp":
+		if len(d.Rates) == 0 {
+			add("rates", codeRequired, "At least one state rate is required")
+		}
+		if len(d.SwitchRates) != len(d.Rates) {
+			add("switchRates", codeOutOfRange, "Give one switch rate per state")
+		}
+		anyArrivals := false
+		for i, r := range d.Rates {
+			if r < 0 {
+				add(fmt.Sprintf("rates[%d]", i), codeNegative, "Rates cannot be negative")
+			}
+			anyArrivals = anyArrivals || r > 0
+		}
+		if len(d.Rates) > 0 && !anyArrivals {
+			add("rates", codeNotPositive, "At least one state must have arrivals")
+		}
+		// A state that is never left would stall arrivals if its rate is 0
+		for i, r := range d.SwitchRates {
+			positive(fmt.Sprintf("switchRates[%d]", i), "Switch rates", r)
+		}
+	case "bimodal":
+		if len(d.Modes) != 2 {
+			add("modes", codeOutOfRange, "Give two modes")
+		}
+		for i, m := range d.Modes {
+			errs = append(errs, validateDistribution(fmt.Sprintf("%s.modes[%d]", path, i), m, modeKinds, whole)...)
+		}
+		checkWeights(len(d.Modes))
+	case "pareto":
+		positive("shape", "Shape", d.Shape)
+		positive("scale", "Scale", d.Scale)
+		if d.Max != 0 && d.Max < d.Scale {
+			add("max", codeOutOfRange, "Max cannot be less than scale")
+		}
+	case "lognormal":
+		if d.Sigma < 0 {
+			add("sigma", codeNegative, "Sigma cannot be negative")
+		}
+	case "discrete":
+		checkValues("values", d.Values)
+		checkWeights(len(d.Values))
+	case "trace":
+		checkValues("values", d.Values)
+	}
+	return errs
+}
+
+// Draw count processes from a validated request. scale is the number of
+// ticks per time unit.
+func generateWorkload(req GenerateRequest, scale int64, seed int64) []Process {
+	rng := rand.New(rand.NewPCG(uint64(seed), 0))
+	gap := newSampler(req.Arrivals, rng, false)
+	burst := newSampler(req.Bursts, rng, false)
+	var priority func() float64
+	if req.Priorities != nil {
+		priority = newSampler(*req.Priorities, rng, true)
+	}
+
+	procs := make([]Process, req.Count)
+	clock := 0.0
+	for i := range procs {
+		if i > 0 {
+			clock += gap()
+		}
+		procs[i] = Process{
+			ID:          fmt.Sprintf("P%d", i+1),
+			ArrivalTime: toTicks(clock, scale),
+			BurstTime:   toTicks(burst(), scale),
+		}
+		// Every process needs some work to do
+		if procs[i].BurstTime < 1 {
+			procs[i].BurstTime = 1
+		}
+		if priority != nil {
+			procs[i].Priority = int(math.Round(priority()))
+		}
+	}
+	return procs
+}
+
+// A time in the time unit rounded to whole ticks, capped at
+// maxGeneratedTicks
+func toTicks(v float64, scale int64) int {
+	ticks := math.Round(v * float64(scale))
+	if ticks > maxGeneratedTicks {
+		return maxGeneratedTicks
+	}
+	return int(ticks)
+}
+
+// A function drawing successive values from a validated distribution
+func newSampler(d DistributionSpec, rng *rand.Rand, whole bool) func() float64 {
+	switch d.Kind {
+	case "poisson":
+		return func() float64 { return rng.ExpFloat64() / d.Rate }
+	case "exponential":
+		return func() float64 { return rng.ExpFloat64() * d.Mean }
+	case "uniform":
+		if whole {
+			// Validated bounds keep the count of values well inside an int64
+			lo, hi := int64(d.Min), int64(d.Max)
+			return func() float64 { return float64(lo + rng.Int64N(hi-lo+1)) }
+		}
+		return func() float64 { return d.Min + rng.Float64()*(d.Max-d.Min) }
+	case "mmpp":
+		// Exponential clocks race for the next arrival and the next state
+		// change; memorylessness lets both restart after either one
+		state := 0
+		return func() float64 {
+			elapsed := 0.0
+			for {
+				arrival := math.Inf(1)
+				if d.Rates[state] > 0 {
+					arrival = rng.ExpFloat64() / d.Rates[state]
+				}
+				change := rng.ExpFloat64() / d.SwitchRates[state]
+				if arrival <= change {
+					return elapsed + arrival
+				}
+				elapsed += change
+				state = (state + 1) % len(d.Rates)
+			}
+		}
+	case "bimodal":
+		modes := []func() float64{newSampler(d.Modes[0], rng, whole), newSampler(d.Modes[1], rng, whole)}
+		return func() float64 { return modes[pick(rng, d.Weights, 2)]() }
+	case "pareto":
+		return func() float64 {
+			// Inverse transform; 1-U keeps the draw away from 0
+			v := d.Scale * math.Pow(1-rng.Float64(), -1/d.Shape)
+			if d.Max > 0 && v > d.Max {
+				v = d.Max
+			}
+			return v
+		}
+	case "lognormal":
+		return func() float64 { return math.Exp(d.Mu + d.Sigma*rng.NormFloat64()) }
+	case "discrete":
+		return func() float64 { return d.Values[pick(rng, d.Weights, len(d.Values))] }
+	case "trace":
+		next := 0
+		return func() float64 {
+			v := d.Values[next]
+			next = (next + 1) % len(d.Values)
+			return v
+		}
+	}
+	return func() float64 { return 0 }
+}
+
+// Index of one of n choices, drawn with the given weights, or uniformly
+// when there are none
+func pick(rng *rand.Rand, weights []float64, n int) int {
+	if len(weights) == 0 {
+		return rng.IntN(n)
+	}
+	total := 0.0
+	for _, w := range weights {
+		total += w
+	}
+	r := rng.Float64() * total
+	for i, w := range weights {
+		if r < w {
+			return i
+		}
+		r -= w
+	}
+	// Rounding can leave r just past the last weight
+	for i := n - 1; i > 0; i-- {
+		if weights[i] > 0 {
+			return i
+		}
+	}
+	return 0
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"math"
+	"math/rand/v2"
+	"testing"
+)
+
+// Whole-number uniform ranges are bounded so that drawing from them can
+// neither overflow nor panic
+func TestUniformPriorityBounds(t *testing.T) {
+	cases := []struct {
+		min, max float64
+		ok       bool
+	}{
+		{1, 10, true},
+		{-maxGeneratedPriority, maxGeneratedPriority, true},
+		{math.MinInt64, math.MaxInt64, false},
+		{0, 1e300, false},
+	}
+	for _, c := range cases {
+		d := DistributionSpec{Kind: "uniform", Min: c.min, Max: c.max}
+		errs := validateDistribution("priorities", d, priorityKinds, true)
+		if ok := len(errs) == 0; ok != c.ok {
+			t.Errorf("[%g, %g]: valid %v, want %v (%v)", c.min, c.max, ok, c.ok, errs)
+			continue
+		}
+		if !c.ok {
+			continue
+		}
+		draw := newSampler(d, rand.New(rand.NewPCG(1, 2)), true)
+		for i := 0; i < 100; i++ {
+			if v := draw(); v < c.min || v > c.max || v != math.Trunc(v) {
+				t.Fatalf("[%g, %g]: drew %g", c.min, c.max, v)
+			}
+		}
+	}
+}
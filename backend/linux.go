@@ -0,0 +1,197 @@
+package main
+
+// Linux scheduling policies, as set with chrt(1)
+const (
+	schedFIFO  = "SCHED_FIFO"
+	schedRR    = "SCHED_RR"
+	schedOther = "SCHED_OTHER"
+	schedIdle  = "SCHED_IDLE"
+)
+
+// ID of the pseudo-cgroup that RT throttling charges real-time tasks to
+const rtBandwidthGroup = "sched_rt"
+
+// Load weight of each nice value from -20 to 19 (sched_prio_to_weight)
+var niceToWeight = [40]int64{
+	88761, 71755, 56483, 46273, 36291,
+	29154, 23254, 18705, 14949, 11916,
+	9548, 7620, 6100, 4904, 3906,
+	3121, 2501, 1991, 1586, 1277,
+	1024, 820, 655, 526, 423,
+	335, 272, 215, 172, 137,
+	110, 87, 70, 56, 45,
+	36, 29, 23, 18, 15,
+}
+
+// Weight of a SCHED_IDLE task, lower than even nice 19
+const idleWeight = 3
+
+// linuxSched holds the per-task state of the Linux scheduling classes: the
+// virtual runtime the fair class (CFS) orders SCHED_OTHER and SCHED_IDLE
+// tasks by.
+type linuxSched struct {
+	vruntime []int64 // Indexed like engine.procs
+
+	// Every fair-class task's vruntime each time it became runnable, for
+	// explaining decisions afterwards; it only grows by running in between
+	placed map[string][]vruntimePoint
+}
+
+type vruntimePoint struct {
+	time     int
+	vruntime int64
+}
+
+func isValidPolicy(policy string) bool {
+	switch policy {
+	case "", schedFIFO, schedRR, schedOther, schedIdle:
+		return true
+	}
+	return false
+}
+
+func isRealtime(p Process) bool {
+	return p.Policy == schedFIFO || p.Policy == schedRR
+}
+
+// Scheduling class rank: real-time first, then normal, then idle
+func schedClass(p Process) int {
+	switch p.Policy {
+	case schedFIFO, schedRR:
+		return 0
+	case schedIdle:
+		return 2
+	}
+	return 1
+}
+
+func loadWeight(p Process) int64 {
+	if p.Policy == schedIdle {
+		return idleWeight
+	}
+	nice := p.Nice
+	if nice < -20 {
+		nice = -20
+	} else if nice > 19 {
+		nice = 19
+	}
+	return niceToWeight[nice+20]
+}
+
+// Virtual runtime of process i, growing the slice for newly forked processes
+func (ls *linuxSched) vr(i int) *int64 {
+	for len(ls.vruntime) <= i {
+		ls.vruntime = append(ls.vruntime, 0)
+	}
+	return &ls.vruntime[i]
+}
+
+// Place a task that just became runnable: like place_entity, it starts no
+// earlier than the smallest vruntime in its class so it cannot starve the
+// tasks that were already there
+func (e *engine) placeLinux(i int) {
+	if isRealtime(e.procs[i]) {
+		return
+	}
+	minVruntime := int64(-1)
+	for j := range e.procs {
+		if j == i || (e.state[j] != stateReady && e.state[j] != stateRunning) {
+			continue
+		}
+		if schedClass(e.procs[j]) != schedClass(e.procs[i]) {
+			continue
+		}
+		if v := *e.linux.vr(j); minVruntime == -1 || v < minVruntime {
+			minVruntime = v
+		}
+	}
+	v := e.linux.vr(i)
+	if *v < minVruntime {
+		*v = minVruntime
+	}
+	if e.linux.placed == nil {
+		e.linux.placed = make(map[string][]vruntimePoint)
+	}
+	id := e.procs[i].ID
+	e.linux.placed[id] = append(e.linux.placed[id], vruntimePoint{e.time, *v})
+}
+
+// Advance the vruntime of a fair-class task that ran for one time unit.
+// Lighter (nicer) tasks age faster, so they get less of the CPU.
+func (e *engine) chargeLinux(i int) {
+	if isRealtime(e.procs[i]) {
+		return
+	}
+	*e.linux.vr(i) += vruntimeCharge(e.procs[i])
+}
+
+// vruntime a fair-class task gains per tick it runs
+func vruntimeCharge(p Process) int64 {
+	return niceToWeight[20] * 1024 / loadWeight(p)
+}
+
+// Whether process a should run ahead of process b under the class hierarchy
+func (e *engine) linuxBefore(a, b int) bool {
+	pa, pb := e.procs[a], e.procs[b]
+	if ca, cb := schedClass(pa), schedClass(pb); ca != cb {
+		return ca < cb
+	}
+	if isRealtime(pa) {
+		return pa.RTPriority > pb.RTPriority
+	}
+	return *e.linux.vr(a) < *e.linux.vr(b)
+}
+
+// Position in the ready queue of the process to run next: the highest
+// real-time priority, else the fair task with the smallest vruntime, with
+// SCHED_IDLE tasks only when nothing else can run. Ties keep queue order,
+// which is what rotates SCHED_RR tasks of equal priority.
+func (e *engine) pickLinux() int {
+	best := -1
+	for pos, j := range e.queue {
+		if !e.canRun(j) {
+			continue
+		}
+		if best == -1 || e.linuxBefore(j, e.queue[best]) {
+			best = pos
+		}
+	}
+	return best
+}
+
+// Whether the running task should give up the CPU to a ready one
+func (e *engine) linuxShouldPreempt() bool {
+	cur := e.running
+	for _, j := range e.queue {
+		if !e.canRun(j) {
+			continue
+		}
+		p, c := e.procs[j], e.procs[cur]
+
+		// A higher class, or a higher real-time priority, always preempts
+		if schedClass(p) < schedClass(c) {
+			return true
+		}
+		if schedClass(p) > schedClass(c) {
+			continue
+		}
+		if isRealtime(c) {
+			if p.RTPriority > c.RTPriority {
+				return true
+			}
+			// SCHED_RR rotates among equal priorities once its quantum is up;
+			// SCHED_FIFO runs until it blocks or finishes
+			if c.Policy == schedRR && p.RTPriority == c.RTPriority && e.sliceUsed >= e.timeQuantum {
+				return true
+			}
+			continue
+		}
+
+		// Fair tasks switch once the running one has had its minimum
+		// granularity and someone else is behind it in vruntime
+		if e.sliceUsed >= e.timeQuantum && *e.linux.vr(j) < *e.linux.vr(cur) {
+			return true
+		}
+	}
+	return false
+}
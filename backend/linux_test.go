@@ -0,0 +1,41 @@
+package main
+
+import "testing"
+
+func TestLinuxSchedules(t *testing.T) {
+	runScheduleCases(t, []scheduleCase{
+		{
+			// Real-time tasks get 3 of every 5 ticks; the fair task runs
+			// while they are throttled
+			name:     "rt throttling",
+			body:     `{"algorithm": "Linux", "timeQuantum": 1, "rtRuntime": 3, "rtPeriod": 5, "processes": [{"id": "F", "burstTime": 6, "policy": "SCHED_FIFO", "rtPriority": 10}, {"id": "N", "burstTime": 3}]}`,
+			timeline: "F 0-3, N 3-5, throttled sched_rt 3-5, F 5-8, N 8-9",
+			times:    "F 2/8, N 6/9",
+			check: func(t *testing.T, r SimulationResponse) {
+				if s := r.RTThrottle; s == nil || s.ThrottleCount != 1 || s.ThrottledTime != 2 || s.Usage != 6 {
+					t.Errorf("rtThrottle = %+v, want 1 throttle of 2 ticks and 6 ticks used", s)
+				}
+			},
+		},
+		{
+			name:     "classes",
+			body:     `{"algorithm": "Linux", "timeQuantum": 2, "processes": [{"id": "O", "burstTime": 3}, {"id": "I", "burstTime": 2, "policy": "SCHED_IDLE"}, {"id": "R", "arrivalTime": 1, "burstTime": 2, "policy": "SCHED_RR", "rtPriority": 10}]}`,
+			timeline: "O 0-1, R 1-3, O 3-5, I 5-7",
+			times:    "O 2/5, I 5/7, R 0/2",
+		},
+		{
+			// SCHED_RR takes turns at equal priority, SCHED_FIFO does not
+			name:     "rr and fifo",
+			body:     `{"algorithm": "Linux", "timeQuantum": 2, "processes": [{"id": "A", "burstTime": 3, "policy": "SCHED_RR", "rtPriority": 5}, {"id": "B", "burstTime": 3, "policy": "SCHED_RR", "rtPriority": 5}, {"id": "X", "burstTime": 3, "policy": "SCHED_FIFO", "rtPriority": 1}, {"id": "Y", "burstTime": 3, "policy": "SCHED_FIFO", "rtPriority": 1}]}`,
+			timeline: "A 0-2, B 2-4, A 4-5, B 5-6, X 6-9, Y 9-12",
+			times:    "A 2/5, B 3/6, X 6/9, Y 9/12",
+		},
+		{
+			// Nice 5 ages about three times as fast as nice 0
+			name:     "nice",
+			body:     `{"algorithm": "Linux", "timeQuantum": 1, "processes": [{"id": "N0", "burstTime": 6}, {"id": "N5", "burstTime": 6, "nice": 5}]}`,
+			timeline: "N0 0-1, N5 1-2, N0 2-5, N5 5-6, N0 6-8, N5 8-12",
+			times:    "N0 2/8, N5 6/12",
+		},
+	})
+}
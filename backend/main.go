@@ -3,7 +3,6 @@ package main
 import (
 	"log"
 	"net/http"
-	"sort"
 	"time"
 
 	"github.com/gin-contrib/cors"
@@ -12,38 +11,206 @@ import (
 
 type Process struct {
 	ID            string `json:"id"`
-	ArrivalTime   int    `json:"arrivalTime"`
-	BurstTime     int    `json:"burstTime"`
+	ArrivalTime   int    `json:"arrivalTime" time:"ticks"`
+	BurstTime     int    `json:"burstTime" time:"ticks"`
 	RemainingTime int    `json:"-"`
 	Priority      int    `json:"priority,omitempty"`
+	Nice          int    `json:"nice,omitempty"`
+
+	// Process creation: children forked part-way through this process's burst
+	ParentID string  `json:"parentId,omitempty"`
+	Spawns   []Spawn `json:"spawns,omitempty"`
+
+	// I/O the process performs part-way through its burst
+	IOBursts []IOBurst `json:"ioBursts,omitempty"`
+
+	// Threads sharing this process; when empty the process is single-threaded.
+	// A process with threads cannot also have Spawns
+	Threads []Thread `json:"threads,omitempty"`
+
+	// CPU quota group the process belongs to
+	Cgroup string `json:"cgroup,omitempty"`
+
+	// Owner of the process for fair-share scheduling
+	User string `json:"user,omitempty"`
+
+	// Linux scheduling policy (SCHED_FIFO, SCHED_RR, SCHED_OTHER or
+	// SCHED_IDLE) and real-time priority, 1-99 with higher running first
+	Policy     string `json:"policy,omitempty"`
+	RTPriority int    `json:"rtPriority,omitempty"`
+
+	// Periodic real-time task: a job every Period, each due Deadline after
+	// its release (default Period). Jobs report the task they belong to.
+	Period         int    `json:"period,omitempty" time:"ticks"`
+	Deadline       int    `json:"deadline,omitempty" time:"ticks"`
+	TaskID         string `json:"taskId,omitempty"`
+	DeadlineMissed bool   `json:"deadlineMissed,omitempty"`
+
+	// Mixed criticality: "LO" (default) or "HI", with the task's worst-case
+	// execution time at each level. Jobs dropped after a mode switch are
+	// marked abandoned.
+	Criticality string `json:"criticality,omitempty"`
+	WCETLo      int    `json:"wcetLo,omitempty" time:"ticks"`
+	WCETHi      int    `json:"wcetHi,omitempty" time:"ticks"`
+	Abandoned   bool   `json:"abandoned,omitempty"`
+
+	// Batch jobs: nodes requested and the user's walltime estimate
+	// (default BurstTime), with the job's bounded slowdown once scheduled
+	Nodes           int     `json:"nodes,omitempty"`
+	Walltime        int     `json:"walltime,omitempty" time:"ticks"`
+	BoundedSlowdown float64 `json:"boundedSlowdown,omitempty"`
 
 	StartTime      int  `json:"-"`
 	IsStarted      bool `json:"-"`
-	CompletionTime int  `json:"completionTime"`
-	TurnaroundTime int  `json:"turnaroundTime"`
-	WaitingTime    int  `json:"waitingTime"`
-	ResponseTime   int  `json:"responseTime"`
+	CompletionTime int  `json:"completionTime" time:"ticks"`
+	TurnaroundTime int  `json:"turnaroundTime" time:"ticks"`
+	WaitingTime    int  `json:"waitingTime" time:"ticks"`
+	ResponseTime   int  `json:"responseTime" time:"ticks"`
+
+	// Turnaround time over burst time
+	NormalizedTurnaround float64 `json:"normalizedTurnaround,omitempty"`
+
+	// Longest stretch spent ready but not running, and whether that went
+	// past the request's starvation threshold
+	LongestReadyWait int  `json:"longestReadyWait" time:"ticks"`
+	Starved          bool `json:"starved,omitempty"`
+}
+
+// Spawn forks a child process once the parent has run for Offset time units
+// of its own burst. With Wait set, the parent blocks until the child exits.
+type Spawn struct {
+	Offset int     `json:"offset" time:"ticks"`
+	Wait   bool    `json:"wait,omitempty"`
+	Child  Process `json:"child"`
+}
+
+// IOBurst blocks a process for Duration time units once it has run for
+// Offset time units of its burst.
+type IOBurst struct {
+	Offset   int `json:"offset" time:"ticks"`
+	Duration int `json:"duration" time:"ticks"`
 }
 
 type TimelineSegment struct {
 	ProcessID string `json:"processId"`
-	StartTime int    `json:"startTime"`
-	EndTime   int    `json:"endTime"`
+	StartTime int    `json:"startTime" time:"ticks"`
+	EndTime   int    `json:"endTime" time:"ticks"`
+
+	// Set for segments that are not a process running: "throttled" marks a
+	// cgroup that has used up its quota for the period
+	Kind   string `json:"kind,omitempty"`
+	Cgroup string `json:"cgroup,omitempty"`
 }
 
 type SimulationRequest struct {
 	Algorithm    string    `json:"algorithm"`
 	IsPreemptive bool      `json:"isPreemptive"`
-	TimeQuantum  int       `json:"timeQuantum,omitempty"`
+	TimeQuantum  int       `json:"timeQuantum,omitempty" time:"ticks"`
 	Processes    []Process `json:"processes"`
+
+	// How forked children get their priority and nice values:
+	// "inherit" (default), "explicit" or "reset-on-fork"
+	InheritPolicy string `json:"inheritPolicy,omitempty"`
+
+	// How threads map onto kernel threads: "one-to-one" (default),
+	// "many-to-one" or "many-to-many" with KernelThreads slots per process
+	ThreadingModel string `json:"threadingModel,omitempty"`
+	KernelThreads  int    `json:"kernelThreads,omitempty"`
+
+	// CPU quotas for groups of processes, applied on top of any algorithm
+	Cgroups []Cgroup `json:"cgroups,omitempty"`
+
+	// Share weights for the FairShare algorithm
+	ShareGroups []ShareGroup `json:"shareGroups,omitempty"`
+	ShareUsers  []ShareUser  `json:"shareUsers,omitempty"`
+
+	// RT throttling for the Linux algorithm, like sched_rt_runtime_us and
+	// sched_rt_period_us; off unless 0 < RTRuntime < RTPeriod
+	RTRuntime int `json:"rtRuntime,omitempty" time:"ticks"`
+	RTPeriod  int `json:"rtPeriod,omitempty" time:"ticks"`
+
+	// Real-time (RM/EDF) options: how long periodic tasks keep releasing
+	// jobs (default one hyperperiod) and the aperiodic server
+	Horizon int           `json:"horizon,omitempty" time:"ticks"`
+	Server  *ServerConfig `json:"server,omitempty"`
+
+	// What happens to LO-criticality tasks after a mode switch under AMC
+	// and EDF-VD: "drop" (default) or "degrade" to background priority
+	LOCriticalityMode string `json:"loCriticalityMode,omitempty"`
+
+	// Batch cluster options: number of nodes, and the runtime below which
+	// jobs count as this long for bounded slowdown (default 10)
+	Nodes         int `json:"nodes,omitempty"`
+	SlowdownBound int `json:"slowdownBound,omitempty" time:"ticks"`
+
+	// How processes the algorithm ties are ordered: a chain of "arrival",
+	// "id" and "input" (default all three, in that order)
+	TieBreaker []string `json:"tieBreaker,omitempty"`
+
+	// Round Robin options: whether a preempted process queues before or
+	// after simultaneous arrivals ("arrivals-first" or "preempted-first"),
+	// how DynamicRR picks its quantum ("median" or "mean") and the
+	// priority growth rates for SelfishRR
+	RRQueueOrder string           `json:"rrQueueOrder,omitempty"`
+	QuantumRule  string           `json:"quantumRule,omitempty"`
+	SelfishRR    *SelfishRRConfig `json:"selfishRR,omitempty"`
+
+	// Which end of the Priority scale runs first: "lower-first" (default,
+	// priority 1 beats priority 2) or "higher-first"
+	PriorityOrder string `json:"priorityOrder,omitempty"`
+
+	// Unit of every time value ("ns", "us", "ms" or "s") and how many
+	// decimal places they may have (default 0, whole units)
+	TimeUnit       string `json:"timeUnit,omitempty"`
+	TimeResolution int    `json:"timeResolution,omitempty"`
+
+	// Histograms to include with the metrics, keyed by metric
+	// ("waitingTime", "turnaroundTime" or "responseTime")
+	Histograms map[string]HistogramConfig `json:"histograms,omitempty"`
+
+	// Processes ready but not running for longer than this are flagged as
+	// starved; 0 (default) flags nobody
+	StarvationThreshold int `json:"starvationThreshold,omitempty" time:"ticks"`
+
+	// Include the ready, running and blocked counts at every change
+	TimeSeries bool `json:"timeSeries,omitempty"`
 }
 
 type SimulationResponse struct {
 	Processes             []Process         `json:"processes"`
 	Timeline              []TimelineSegment `json:"timeline"`
-	AverageWaitingTime    float64           `json:"averageWaitingTime"`
-	AverageTurnaroundTime float64           `json:"averageTurnaroundTime"`
-	AverageResponseTime   float64           `json:"averageResponseTime"`
+	AverageWaitingTime    float64           `json:"averageWaitingTime" time:"average"`
+	AverageTurnaroundTime float64           `json:"averageTurnaroundTime" time:"average"`
+	AverageResponseTime   float64           `json:"averageResponseTime" time:"average"`
+
+	// Makespan, utilization, throughput, context switches and the like
+	Metrics *Metrics `json:"metrics,omitempty"`
+
+	ProcessTree []ProcessTreeNode `json:"processTree,omitempty"`
+	CgroupStats []CgroupStats     `json:"cgroupStats,omitempty"`
+	ShareReport []ShareReport     `json:"shareReport,omitempty"`
+	RTThrottle  *CgroupStats      `json:"rtThrottle,omitempty"`
+	Server      *ServerReport     `json:"server,omitempty"`
+
+	MixedCriticality *MixedCriticalityReport `json:"mixedCriticality,omitempty"`
+	Batch            *BatchReport            `json:"batch,omitempty"`
+
+	// Tie-breaker chain the simulation used
+	TieBreaker []string `json:"tieBreaker"`
+
+	// Caveats about the result, such as an algorithm chosen for contrast
+	// that is known to perform badly
+	Warnings []string `json:"warnings,omitempty"`
+
+	// Time unit and resolution of the request, and the averages as exact
+	// fractions
+	TimeUnit       string         `json:"timeUnit,omitempty"`
+	TimeResolution int            `json:"timeResolution,omitempty"`
+	ExactAverages  *ExactAverages `json:"exactAverages,omitempty"`
+
+	// Linux only: where each fair-class task's vruntime was placed, for
+	// sessions to explain decisions by
+	vruntimes map[string][]vruntimePoint
 }
 
 func main() {
@@ -52,7 +219,7 @@ func main() {
 	// Configure CORS
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"*"},
-		AllowMethods:     []string{"POST", "GET", "OPTIONS"},
+		AllowMethods:     []string{"POST", "GET", "DELETE", "OPTIONS"},
 		AllowHeaders:     []string{"Origin", "Content-Type"},
 		ExposeHeaders:    []string{"Content-Length"},
 		AllowCredentials: true,
@@ -60,6 +227,17 @@ func main() {
 	}))
 
 	r.POST("/simulate", handleSimulation)
+	r.POST("/compare", handleCompare)
+	r.POST("/sweep", handleSweep)
+	r.POST("/generate", handleGenerate)
+	r.POST("/experiment", handleExperiment)
+	r.POST("/adversary", handleAdversary)
+	r.POST("/sessions", handleCreateSession)
+	r.GET("/sessions/:id", handleGetSession)
+	r.DELETE("/sessions/:id", handleDeleteSession)
+	r.POST("/sessions/:id/step", handleSessionStep)
+	r.POST("/sessions/:id/run-until", handleSessionRunUntil)
+	r.POST("/sessions/:id/back", handleSessionBack)
 
 	log.Println("Server running on port 8080")
 	r.Run(":8080")
@@ -67,17 +245,67 @@ func main() {
 
 func handleSimulation(c *gin.Context) {
 	var req SimulationRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+	scale, raw, ok := decodeBody(c, &req)
+	if !ok {
 		return
 	}
 
-	// Validate the request
-	if len(req.Processes) == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "No processes provided"})
+	// Validate the whole request before filling in defaults, so every
+	// problem is reported at once against the values that were sent
+	if errs := validateRequest(req, raw); len(errs) > 0 {
+		rejectRequest(c, errs)
 		return
 	}
 
+	response, ok := simulate(req, scale)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown algorithm"})
+		return
+	}
+	respond(c, response, req.TimeResolution)
+}
+
+// Read a request body and decode it into dst with every time value scaled
+// to ticks. Returns the ticks per time unit and the parsed body, which
+// validation uses to tell a missing value from a zero one. On failure the
+// error response has been sent and ok is false.
+func decodeBody(c *gin.Context, dst any) (scale int64, raw map[string]interface{}, ok bool) {
+	body, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return 0, nil, false
+	}
+	raw, ok = parseRequest(body)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return 0, nil, false
+	}
+
+	scale, errs := decodeRequest(raw, dst)
+	if len(errs) > 0 {
+		rejectRequest(c, errs)
+		return 0, nil, false
+	}
+	return scale, raw, true
+}
+
+// Run a validated request and fill in everything the response carries.
+// Returns false if the algorithm is unknown. scale is the number of ticks
+// per time unit.
+func simulate(req SimulationRequest, scale int64) (SimulationResponse, bool) {
+	// Defaults are one and ten whole time units, not ticks
+	if req.TimeQuantum <= 0 {
+		req.TimeQuantum = int(scale)
+	}
+	if req.SlowdownBound <= 0 {
+		req.SlowdownBound = defaultSlowdownBound * int(scale)
+	}
+
+	tb := requestTieBreaker(req)
+	req.TieBreaker = tb
+	preemptedFirst := req.RRQueueOrder == "preempted-first"
+	higherFirst := req.PriorityOrder == "higher-first"
+
 	// Initialize remaining time for all processes
 	for i := range req.Processes {
 		req.Processes[i].RemainingTime = req.Processes[i].BurstTime
@@ -85,55 +313,115 @@ func handleSimulation(c *gin.Context) {
 
 	var response SimulationResponse
 
+	// Workloads that fork, block on I/O, are split into threads or run under
+	// CPU quotas need the step engine, since who can run changes while the
+	// simulation is running
+	if needsEngine(req) {
+		switch {
+		case hasThreads(req.Processes):
+			response = runThreaded(req)
+		case hasSpawns(req.Processes):
+			response = runProcessTree(req)
+		default:
+			response = runEngine(req)
+		}
+		return complete(req, response, scale), true
+	}
+
 	// Run appropriate scheduling algorithm
 	switch req.Algorithm {
 	case "FCFS":
-		response = runFCFS(req.Processes)
+		response = runFCFS(req.Processes, tb)
 	case "SJF":
 		if req.IsPreemptive {
-			response = runSRTF(req.Processes) // Preemptive SJF is SRTF
+			response = runSRTF(req.Processes, tb) // Preemptive SJF is SRTF
 		} else {
-			response = runSJF(req.Processes) // Non-preemptive SJF
+			response = runSJF(req.Processes, tb) // Non-preemptive SJF
 		}
 	case "RR":
-		response = runRoundRobin(req.Processes, req.TimeQuantum)
+		response = runRoundRobin(req.Processes, req.TimeQuantum, "", preemptedFirst, tb)
+	case "DynamicRR":
+		rule := req.QuantumRule
+		if rule == "" {
+			rule = "median"
+		}
+		response = runRoundRobin(req.Processes, req.TimeQuantum, rule, preemptedFirst, tb)
+	case "SelfishRR":
+		response = runSelfishRR(req.Processes, req.TimeQuantum, req.SelfishRR, preemptedFirst, tb)
 	case "Priority":
 		if req.IsPreemptive {
-			response = runPreemptivePriority(req.Processes)
+			response = runPreemptivePriority(req.Processes, higherFirst, tb)
 		} else {
-			response = runNonPreemptivePriority(req.Processes)
+			response = runNonPreemptivePriority(req.Processes, higherFirst, tb)
 		}
+	case "FairShare", "Linux", "VRR", "PriorityRR":
+		response = runEngine(req) // These only exist in the step engine
+	case "LJF":
+		if req.IsPreemptive {
+			// Longest Remaining Time First switches whenever another process
+			// overtakes the running one, which only the step engine models
+			response = runEngine(req)
+		} else {
+			response = runLJF(req.Processes, tb)
+		}
+	case "RM", "EDF", "AMC", "EDF-VD":
+		response = runRealtime(req)
+	case "BatchFCFS", "EASY", "Conservative":
+		response = runBatch(req)
 	default:
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown algorithm"})
-		return
+		return response, false
 	}
 
-	// Calculate average times
-	var totalWaitingTime, totalTurnaroundTime, totalResponseTime int
-	for _, p := range response.Processes {
-		totalWaitingTime += p.WaitingTime
-		totalTurnaroundTime += p.TurnaroundTime
-		totalResponseTime += p.ResponseTime
-	}
+	return complete(req, response, scale), true
+}
+
+// Simulate a request that passed validation, so its algorithm is known
+func simulateValidated(req SimulationRequest, scale int64) SimulationResponse {
+	response, _ := simulate(req, scale)
+	return response
+}
 
-	numProcesses := float64(len(response.Processes))
-	response.AverageWaitingTime = float64(totalWaitingTime) / numProcesses
-	response.AverageTurnaroundTime = float64(totalTurnaroundTime) / numProcesses
-	response.AverageResponseTime = float64(totalResponseTime) / numProcesses
+// Fill in what every response carries
+func complete(req SimulationRequest, response SimulationResponse, scale int64) SimulationResponse {
+	response = withMetrics(response, req, scale)
+	response.TieBreaker = req.TieBreaker
+	response.Warnings = algorithmWarnings(req)
+	response.TimeUnit = req.TimeUnit
+	response.TimeResolution = req.TimeResolution
+	response.ExactAverages = exactAverages(response.Processes, scale)
+	return response
+}
 
-	c.JSON(http.StatusOK, response)
+// Send a response with times converted back to the request's time unit
+func respond(c *gin.Context, response interface{}, resolution int) {
+	body, err := encodeResponse(response, resolution)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not encode response"})
+		return
+	}
+	c.JSON(http.StatusOK, body)
+}
+
+// Caveats that come with the chosen algorithm
+func algorithmWarnings(req SimulationRequest) []string {
+	if req.Algorithm != "LJF" {
+		return nil
+	}
+	if req.IsPreemptive {
+		return []string{"LRTF maximizes average waiting time; it is here for contrast with SRTF, not for real use"}
+	}
+	return []string{"LJF maximizes average waiting time; it is here for contrast with SJF, not for real use"}
 }
 
 // First Come First Served (FCFS) scheduling algorithm
-func runFCFS(processes []Process) SimulationResponse {
-	// Make a copy of processes to avoid modifying the original
+func runFCFS(processes []Process, tb tieBreaker) SimulationResponse {
+	// Sort processes by arrival time, breaking ties with the tie-breaker.
+	// Sort positions rather than processes so input order is still known.
+	order := arrivalOrder(processes, tb.ranks(processes))
 	procs := make([]Process, len(processes))
-	copy(procs, processes)
-
-	// Sort processes by arrival time
-	sort.Slice(procs, func(i, j int) bool {
-		return procs[i].ArrivalTime < procs[j].ArrivalTime
-	})
+	for i, k := range order {
+		procs[i] = processes[k]
+	}
 
 	var timeline []TimelineSegment
 	currentTime := 0
@@ -173,222 +461,35 @@ func runFCFS(processes []Process) SimulationResponse {
 }
 
 // Shortest Job First (SJF) - Non-preemptive
-func runSJF(processes []Process) SimulationResponse {
-	// Make a copy of processes
-	procs := make([]Process, len(processes))
-	copy(procs, processes)
-
-	var timeline []TimelineSegment
-	var completed []Process
-	currentTime := 0
-	remainingProcesses := len(procs)
-
-	// Find process with earliest arrival time to set initial currentTime
-	earliestArrival := procs[0].ArrivalTime
-	for _, p := range procs {
-		if p.ArrivalTime < earliestArrival {
-			earliestArrival = p.ArrivalTime
-		}
-	}
-	currentTime = earliestArrival
-
-	for remainingProcesses > 0 {
-		// Find the process with shortest burst time among arrived processes
-		minBurstTime := -1
-		selectedIdx := -1
-
-		for i, p := range procs {
-			if p.RemainingTime > 0 && p.ArrivalTime <= currentTime {
-				if minBurstTime == -1 || p.BurstTime < minBurstTime {
-					minBurstTime = p.BurstTime
-					selectedIdx = i
-				}
-			}
-		}
-
-		// If no process is available at current time, advance time to next arrival
-		if selectedIdx == -1 {
-			nextArrival := -1
-			for _, p := range procs {
-				if p.RemainingTime > 0 {
-					if nextArrival == -1 || p.ArrivalTime < nextArrival {
-						nextArrival = p.ArrivalTime
-					}
-				}
-			}
-			currentTime = nextArrival
-			continue
-		}
-
-		// Set start time for the selected process if it hasn't started yet
-		if !procs[selectedIdx].IsStarted {
-			procs[selectedIdx].StartTime = currentTime
-			procs[selectedIdx].ResponseTime = procs[selectedIdx].StartTime - procs[selectedIdx].ArrivalTime
-			procs[selectedIdx].IsStarted = true
-		}
-
-		// Add to timeline
-		segment := TimelineSegment{
-			ProcessID: procs[selectedIdx].ID,
-			StartTime: currentTime,
-			EndTime:   currentTime + procs[selectedIdx].RemainingTime,
-		}
-		timeline = append(timeline, segment)
-
-		// Update current time
-		currentTime += procs[selectedIdx].RemainingTime
+func runSJF(processes []Process, tb tieBreaker) SimulationResponse {
+	response, completionOrder := runNonPreemptiveByKey(processes, tb, func(p *Process) int { return p.BurstTime })
 
-		// Set completion time, turnaround time, and waiting time
-		procs[selectedIdx].CompletionTime = currentTime
-		procs[selectedIdx].TurnaroundTime = procs[selectedIdx].CompletionTime - procs[selectedIdx].ArrivalTime
-		procs[selectedIdx].WaitingTime = procs[selectedIdx].TurnaroundTime - procs[selectedIdx].BurstTime
-
-		// Mark process as completed
-		procs[selectedIdx].RemainingTime = 0
-		completed = append(completed, procs[selectedIdx])
-		remainingProcesses--
+	// SJF lists processes in the order they completed
+	completed := make([]Process, len(completionOrder))
+	for k, i := range completionOrder {
+		completed[k] = response.Processes[i]
 	}
+	response.Processes = completed
+	return response
+}
 
-	return SimulationResponse{
-		Processes: completed,
-		Timeline:  timeline,
-	}
+// Longest Job First (LJF) - Non-preemptive. Processes stay in input order.
+func runLJF(processes []Process, tb tieBreaker) SimulationResponse {
+	response, _ := runNonPreemptiveByKey(processes, tb, func(p *Process) int { return -p.BurstTime })
+	return response
 }
 
 // Shortest Remaining Time First (SRTF) - Preemptive SJF
-func runSRTF(processes []Process) SimulationResponse {
-	// Make a copy of processes
-	procs := make([]Process, len(processes))
-	copy(procs, processes)
-
-	var timeline []TimelineSegment
-	currentTime := 0
-	remainingProcesses := len(procs)
-
-	// Initialize tracking variables
-	for i := range procs {
-		procs[i].IsStarted = false
-	}
-
-	// Find process with earliest arrival time
-	earliestArrival := procs[0].ArrivalTime
-	for _, p := range procs {
-		if p.ArrivalTime < earliestArrival {
-			earliestArrival = p.ArrivalTime
-		}
-	}
-	currentTime = earliestArrival
-
-	// Track the currently running process
-	var currentProcess int = -1
-	var currentSegmentStart int = 0
-
-	// Continue until all processes complete
-	for remainingProcesses > 0 {
-		// Find process with shortest remaining time among arrived processes
-		minRemainingTime := -1
-		selectedIdx := -1
-
-		for i, p := range procs {
-			if p.RemainingTime > 0 && p.ArrivalTime <= currentTime {
-				if minRemainingTime == -1 || p.RemainingTime < minRemainingTime {
-					minRemainingTime = p.RemainingTime
-					selectedIdx = i
-				}
-			}
-		}
-
-		// If no process is available, advance time to next arrival
-		if selectedIdx == -1 {
-			nextArrival := -1
-			for _, p := range procs {
-				if p.RemainingTime > 0 {
-					if nextArrival == -1 || p.ArrivalTime < nextArrival {
-						nextArrival = p.ArrivalTime
-					}
-				}
-			}
-
-			// If we had a process running before, add its segment to timeline
-			if currentProcess != -1 {
-				timeline = append(timeline, TimelineSegment{
-					ProcessID: procs[currentProcess].ID,
-					StartTime: currentSegmentStart,
-					EndTime:   currentTime,
-				})
-				currentProcess = -1
-			}
-
-			currentTime = nextArrival
-			continue
-		}
-
-		// If this is the first time this process gets CPU, record response time
-		if !procs[selectedIdx].IsStarted {
-			procs[selectedIdx].StartTime = currentTime
-			procs[selectedIdx].ResponseTime = currentTime - procs[selectedIdx].ArrivalTime
-			procs[selectedIdx].IsStarted = true
-		}
-
-		// If there's a process switch, record the previous process's segment
-		if currentProcess != selectedIdx && currentProcess != -1 {
-			timeline = append(timeline, TimelineSegment{
-				ProcessID: procs[currentProcess].ID,
-				StartTime: currentSegmentStart,
-				EndTime:   currentTime,
-			})
-			currentSegmentStart = currentTime
-		} else if currentProcess == -1 {
-			currentSegmentStart = currentTime
-		}
-
-		currentProcess = selectedIdx
-
-		// Determine how long this process will run
-		// Either until completion or until next process arrival that could preempt it
-		timeSlice := procs[selectedIdx].RemainingTime
-
-		// Find next arrival time that might preempt this process
-		for _, p := range procs {
-			if p.RemainingTime > 0 && p.ArrivalTime > currentTime && p.ArrivalTime < currentTime+timeSlice {
-				// Only consider arrivals that could preempt (have shorter remaining time)
-				if p.BurstTime < procs[selectedIdx].RemainingTime-(p.ArrivalTime-currentTime) {
-					timeSlice = p.ArrivalTime - currentTime
-				}
-			}
-		}
-
-		// Update current time and process's remaining time
-		currentTime += timeSlice
-		procs[selectedIdx].RemainingTime -= timeSlice
-
-		// If process completes
-		if procs[selectedIdx].RemainingTime == 0 {
-			// Add final segment to timeline
-			timeline = append(timeline, TimelineSegment{
-				ProcessID: procs[selectedIdx].ID,
-				StartTime: currentSegmentStart,
-				EndTime:   currentTime,
-			})
-
-			// Set completion time and calculate metrics
-			procs[selectedIdx].CompletionTime = currentTime
-			procs[selectedIdx].TurnaroundTime = procs[selectedIdx].CompletionTime - procs[selectedIdx].ArrivalTime
-			procs[selectedIdx].WaitingTime = procs[selectedIdx].TurnaroundTime - procs[selectedIdx].BurstTime
-
-			remainingProcesses--
-			currentProcess = -1
-		}
-	}
-
-	return SimulationResponse{
-		Processes: procs,
-		Timeline:  timeline,
-	}
+func runSRTF(processes []Process, tb tieBreaker) SimulationResponse {
+	return runPreemptiveByKey(processes, tb, func(p *Process) int { return p.RemainingTime })
 }
 
-// Round Robin scheduling algorithm
-func runRoundRobin(processes []Process, timeQuantum int) SimulationResponse {
+// Round Robin scheduling algorithm. With a quantum rule ("median" or
+// "mean") the quantum is recomputed at every dispatch from the remaining
+// bursts of the ready processes (dynamic-quantum RR). With preemptedFirst,
+// a process preempted at the same instant others arrive rejoins the queue
+// ahead of them.
+func runRoundRobin(processes []Process, timeQuantum int, quantumRule string, preemptedFirst bool, tb tieBreaker) SimulationResponse {
 	if timeQuantum <= 0 {
 		timeQuantum = 1 // Default time quantum
 	}
@@ -399,54 +500,40 @@ func runRoundRobin(processes []Process, timeQuantum int) SimulationResponse {
 
 	var timeline []TimelineSegment
 	var readyQueue []int // Queue of process indices
-	currentTime := 0
+	readyRemaining := 0  // Total remaining time of the ready queue, for the mean quantum
 
 	// Initialize tracking variables
 	for i := range procs {
 		procs[i].IsStarted = false
 	}
 
-	// Find earliest arrival
-	earliestArrival := procs[0].ArrivalTime
-	for _, p := range procs {
-		if p.ArrivalTime < earliestArrival {
-			earliestArrival = p.ArrivalTime
-		}
-	}
-	currentTime = earliestArrival
-
-	// Add initially available processes to ready queue
-	for i, p := range procs {
-		if p.ArrivalTime <= currentTime {
-			readyQueue = append(readyQueue, i)
-		}
+	// Processes join the ready queue in arrival order, with processes
+	// arriving together in tie-breaker order
+	order := arrivalOrder(procs, tb.ranks(procs))
+	next := 0 // Next process in arrival order that has not arrived yet
+	enqueue := func(i int) {
+		readyQueue = append(readyQueue, i)
+		readyRemaining += procs[i].RemainingTime
 	}
+	currentTime := procs[order[0]].ArrivalTime
 
 	// Continue until all processes complete
 	completedCount := 0
 	for completedCount < len(procs) {
-		if len(readyQueue) == 0 {
-			// Find next arriving process if ready queue is empty
-			nextArrival := -1
-			nextIndex := -1
-			for i, p := range procs {
-				if p.RemainingTime > 0 && p.ArrivalTime > currentTime {
-					if nextArrival == -1 || p.ArrivalTime < nextArrival {
-						nextArrival = p.ArrivalTime
-						nextIndex = i
-					}
-				}
-			}
-			if nextIndex == -1 {
-				break // No more processes to execute
-			}
-			currentTime = nextArrival
-			readyQueue = append(readyQueue, nextIndex)
+		// If the ready queue is empty, advance time to the next arrival;
+		// everything arriving by then becomes ready
+		if len(readyQueue) == 0 && procs[order[next]].ArrivalTime > currentTime {
+			currentTime = procs[order[next]].ArrivalTime
+		}
+		for next < len(order) && procs[order[next]].ArrivalTime <= currentTime {
+			enqueue(order[next])
+			next++
 		}
 
 		// Get next process from ready queue
 		currentProcessIdx := readyQueue[0]
 		readyQueue = readyQueue[1:] // Dequeue
+		readyRemaining -= procs[currentProcessIdx].RemainingTime
 
 		// Record response time if process hasn't started
 		if !procs[currentProcessIdx].IsStarted {
@@ -457,31 +544,54 @@ func runRoundRobin(processes []Process, timeQuantum int) SimulationResponse {
 
 		// Calculate execution time for this quantum
 		executeTime := timeQuantum
+		switch quantumRule {
+		case "mean":
+			n := len(readyQueue) + 1
+			executeTime = (readyRemaining + procs[currentProcessIdx].RemainingTime + n - 1) / n
+			if executeTime < 1 {
+				executeTime = 1
+			}
+		case "median":
+			remaining := []int{procs[currentProcessIdx].RemainingTime}
+			for _, i := range readyQueue {
+				remaining = append(remaining, procs[i].RemainingTime)
+			}
+			executeTime = dynamicQuantum(remaining, quantumRule)
+		}
 		if procs[currentProcessIdx].RemainingTime < executeTime {
 			executeTime = procs[currentProcessIdx].RemainingTime
 		}
 
 		// Add to timeline
-		timeline = append(timeline, TimelineSegment{
-			ProcessID: procs[currentProcessIdx].ID,
-			StartTime: currentTime,
-			EndTime:   currentTime + executeTime,
-		})
+		if executeTime > 0 {
+			timeline = append(timeline, TimelineSegment{
+				ProcessID: procs[currentProcessIdx].ID,
+				StartTime: currentTime,
+				EndTime:   currentTime + executeTime,
+			})
+		}
 
 		// Update time and remaining time
 		currentTime += executeTime
 		procs[currentProcessIdx].RemainingTime -= executeTime
 
-		// Check for new arrivals during this time quantum
-		for i, p := range procs {
-			if p.RemainingTime > 0 && p.ArrivalTime > currentTime-executeTime && p.ArrivalTime <= currentTime && !contains(readyQueue, i) {
-				readyQueue = append(readyQueue, i)
+		// New arrivals during this time quantum join the queue. Under the
+		// preempted-first convention, those arriving right as the quantum
+		// ends queue up behind the preempted process.
+		for next < len(order) && procs[order[next]].ArrivalTime < currentTime {
+			enqueue(order[next])
+			next++
+		}
+		if !preemptedFirst {
+			for next < len(order) && procs[order[next]].ArrivalTime == currentTime {
+				enqueue(order[next])
+				next++
 			}
 		}
 
 		// If process still has remaining time, add back to ready queue
 		if procs[currentProcessIdx].RemainingTime > 0 {
-			readyQueue = append(readyQueue, currentProcessIdx)
+			enqueue(currentProcessIdx)
 		} else {
 			// Process completed
 			procs[currentProcessIdx].CompletionTime = currentTime
@@ -498,222 +608,12 @@ func runRoundRobin(processes []Process, timeQuantum int) SimulationResponse {
 }
 
 // Non-Preemptive Priority Scheduling
-func runNonPreemptivePriority(processes []Process) SimulationResponse {
-	// Make a copy of processes
-	procs := make([]Process, len(processes))
-	copy(procs, processes)
-
-	var timeline []TimelineSegment
-	currentTime := 0
-	remainingProcesses := len(procs)
-
-	// Find earliest arrival
-	earliestArrival := procs[0].ArrivalTime
-	for _, p := range procs {
-		if p.ArrivalTime < earliestArrival {
-			earliestArrival = p.ArrivalTime
-		}
-	}
-	currentTime = earliestArrival
-
-	for remainingProcesses > 0 {
-		// Find process with highest priority (lowest number) among arrived processes
-		highestPriority := -1
-		selectedIdx := -1
-
-		for i, p := range procs {
-			if p.RemainingTime > 0 && p.ArrivalTime <= currentTime {
-				if highestPriority == -1 || p.Priority < highestPriority {
-					highestPriority = p.Priority
-					selectedIdx = i
-				}
-			}
-		}
-
-		// If no process is available, advance time to next arrival
-		if selectedIdx == -1 {
-			nextArrival := -1
-			for _, p := range procs {
-				if p.RemainingTime > 0 {
-					if nextArrival == -1 || p.ArrivalTime < nextArrival {
-						nextArrival = p.ArrivalTime
-					}
-				}
-			}
-			currentTime = nextArrival
-			continue
-		}
-
-		// Record start time and response time if not started
-		if !procs[selectedIdx].IsStarted {
-			procs[selectedIdx].StartTime = currentTime
-			procs[selectedIdx].ResponseTime = currentTime - procs[selectedIdx].ArrivalTime
-			procs[selectedIdx].IsStarted = true
-		}
-
-		// Add to timeline
-		segment := TimelineSegment{
-			ProcessID: procs[selectedIdx].ID,
-			StartTime: currentTime,
-			EndTime:   currentTime + procs[selectedIdx].RemainingTime,
-		}
-		timeline = append(timeline, segment)
-
-		// Update time
-		currentTime += procs[selectedIdx].RemainingTime
-
-		// Set completion time and metrics
-		procs[selectedIdx].CompletionTime = currentTime
-		procs[selectedIdx].TurnaroundTime = procs[selectedIdx].CompletionTime - procs[selectedIdx].ArrivalTime
-		procs[selectedIdx].WaitingTime = procs[selectedIdx].TurnaroundTime - procs[selectedIdx].BurstTime
-
-		// Mark process as completed
-		procs[selectedIdx].RemainingTime = 0
-		remainingProcesses--
-	}
-
-	return SimulationResponse{
-		Processes: procs,
-		Timeline:  timeline,
-	}
+func runNonPreemptivePriority(processes []Process, higherFirst bool, tb tieBreaker) SimulationResponse {
+	response, _ := runNonPreemptiveByKey(processes, tb, func(p *Process) int { return priorityRank(*p, higherFirst) })
+	return response
 }
 
 // Preemptive Priority Scheduling
-func runPreemptivePriority(processes []Process) SimulationResponse {
-	// Make a copy of processes
-	procs := make([]Process, len(processes))
-	copy(procs, processes)
-
-	var timeline []TimelineSegment
-	currentTime := 0
-	remainingProcesses := len(procs)
-
-	// Initialize tracking variables
-	for i := range procs {
-		procs[i].IsStarted = false
-	}
-
-	// Find earliest arrival
-	earliestArrival := procs[0].ArrivalTime
-	for _, p := range procs {
-		if p.ArrivalTime < earliestArrival {
-			earliestArrival = p.ArrivalTime
-		}
-	}
-	currentTime = earliestArrival
-
-	// Track the currently running process
-	var currentProcess int = -1
-	var currentSegmentStart int = 0
-
-	for remainingProcesses > 0 {
-		// Find process with highest priority (lowest number) among arrived processes
-		highestPriority := -1
-		selectedIdx := -1
-
-		for i, p := range procs {
-			if p.RemainingTime > 0 && p.ArrivalTime <= currentTime {
-				if highestPriority == -1 || p.Priority < highestPriority {
-					highestPriority = p.Priority
-					selectedIdx = i
-				}
-			}
-		}
-
-		// If no process is available, advance time to next arrival
-		if selectedIdx == -1 {
-			nextArrival := -1
-			for _, p := range procs {
-				if p.RemainingTime > 0 {
-					if nextArrival == -1 || p.ArrivalTime < nextArrival {
-						nextArrival = p.ArrivalTime
-					}
-				}
-			}
-
-			// If we had a process running before, add its segment to timeline
-			if currentProcess != -1 {
-				timeline = append(timeline, TimelineSegment{
-					ProcessID: procs[currentProcess].ID,
-					StartTime: currentSegmentStart,
-					EndTime:   currentTime,
-				})
-				currentProcess = -1
-			}
-
-			currentTime = nextArrival
-			continue
-		}
-
-		// If this is the first time this process gets CPU, record response time
-		if !procs[selectedIdx].IsStarted {
-			procs[selectedIdx].StartTime = currentTime
-			procs[selectedIdx].ResponseTime = currentTime - procs[selectedIdx].ArrivalTime
-			procs[selectedIdx].IsStarted = true
-		}
-
-		// If there's a process switch, record the previous process's segment
-		if currentProcess != selectedIdx && currentProcess != -1 {
-			timeline = append(timeline, TimelineSegment{
-				ProcessID: procs[currentProcess].ID,
-				StartTime: currentSegmentStart,
-				EndTime:   currentTime,
-			})
-			currentSegmentStart = currentTime
-		} else if currentProcess == -1 {
-			currentSegmentStart = currentTime
-		}
-
-		currentProcess = selectedIdx
-
-		// Determine how long this process will run
-		timeSlice := procs[selectedIdx].RemainingTime
-
-		// Find next arrival time that might preempt this process
-		for _, p := range procs {
-			if p.RemainingTime > 0 && p.ArrivalTime > currentTime && p.ArrivalTime < currentTime+timeSlice {
-				// Only consider arrivals that could preempt (have higher priority)
-				if p.Priority < procs[selectedIdx].Priority {
-					timeSlice = p.ArrivalTime - currentTime
-				}
-			}
-		}
-
-		// Update current time and process's remaining time
-		currentTime += timeSlice
-		procs[selectedIdx].RemainingTime -= timeSlice
-
-		// If process completes
-		if procs[selectedIdx].RemainingTime == 0 {
-			// Add final segment to timeline
-			timeline = append(timeline, TimelineSegment{
-				ProcessID: procs[selectedIdx].ID,
-				StartTime: currentSegmentStart,
-				EndTime:   currentTime,
-			})
-
-			// Set completion time and calculate metrics
-			procs[selectedIdx].CompletionTime = currentTime
-			procs[selectedIdx].TurnaroundTime = procs[selectedIdx].CompletionTime - procs[selectedIdx].ArrivalTime
-			procs[selectedIdx].WaitingTime = procs[selectedIdx].TurnaroundTime - procs[selectedIdx].BurstTime
-
-			remainingProcesses--
-			currentProcess = -1
-		}
-	}
-
-	return SimulationResponse{
-		Processes: procs,
-		Timeline:  timeline,
-	}
-}
-
-// Helper function to check if a slice contains a value
-func contains(slice []int, val int) bool {
-	for _, item := range slice {
-		if item == val {
-			return true
-		}
-	}
-	return false
+func runPreemptivePriority(processes []Process, higherFirst bool, tb tieBreaker) SimulationResponse {
+	return runPreemptiveByKey(processes, tb, func(p *Process) int { return priorityRank(*p, higherFirst) })
 }
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// scheduleCase pins the schedule one /simulate request produces: its
+// timeline, every process's waiting and turnaround times, and anything
+// else check looks at
+type scheduleCase struct {
+	name     string
+	body     string
+	timeline string // "P1 0-2, throttled sched_rt 4-6, mode-switch 5"
+	times    string // Waiting/turnaround per process: "P1 0/2, P2 2/5"
+	check    func(t *testing.T, r SimulationResponse)
+}
+
+func TestLongestJobSchedules(t *testing.T) {
+	warned := func(warning string) func(t *testing.T, r SimulationResponse) {
+		return func(t *testing.T, r SimulationResponse) {
+			if len(r.Warnings) != 1 || r.Warnings[0] != warning {
+				t.Errorf("warnings %q, want %q", r.Warnings, warning)
+			}
+		}
+	}
+	nonPreemptive := `"processes": [{"id": "P1", "burstTime": 2}, {"id": "P2", "burstTime": 6}, {"id": "P3", "arrivalTime": 1, "burstTime": 9}]}`
+	preemptive := `"isPreemptive": true, "processes": [{"id": "P1", "burstTime": 4}, {"id": "P2", "arrivalTime": 1, "burstTime": 5}, {"id": "P3", "arrivalTime": 2, "burstTime": 2}]}`
+	runScheduleCases(t, []scheduleCase{
+		{
+			name:     "ljf",
+			body:     `{"algorithm": "LJF", ` + nonPreemptive,
+			timeline: "P2 0-6, P3 6-15, P1 15-17",
+			times:    "P1 15/17, P2 0/6, P3 5/14",
+			check:    warned("LJF maximizes average waiting time; it is here for contrast with SJF, not for real use"),
+		},
+		{
+			name:     "sjf",
+			body:     `{"algorithm": "SJF", ` + nonPreemptive,
+			timeline: "P1 0-2, P2 2-8, P3 8-17",
+			times:    "P1 0/2, P2 2/8, P3 7/16",
+		},
+		{
+			// The lead passes back and forth as the longest jobs wear down,
+			// so everything finishes near the end. A process only tied with
+			// the running one waits for it to fall behind
+			name:     "lrtf",
+			body:     `{"algorithm": "LJF", ` + preemptive,
+			timeline: "P1 0-1, P2 1-4, P1 4-6, P2 6-7, P3 7-9, P1 9-10, P2 10-11",
+			times:    "P1 6/10, P2 5/10, P3 5/7",
+			check:    warned("LRTF maximizes average waiting time; it is here for contrast with SRTF, not for real use"),
+		},
+		{
+			name:     "srtf",
+			body:     `{"algorithm": "SJF", ` + preemptive,
+			timeline: "P1 0-4, P3 4-6, P2 6-11",
+			times:    "P1 0/4, P2 5/10, P3 2/4",
+		},
+	})
+}
+
+func runScheduleCases(t *testing.T, cases []scheduleCase) {
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := simulateResponse(t, tc.body)
+			if got := timelineString(r.Timeline); got != tc.timeline {
+				t.Errorf("timeline:\n got %s\nwant %s", got, tc.timeline)
+			}
+			if got := timesString(r.Processes); got != tc.times {
+				t.Errorf("waiting/turnaround:\n got %s\nwant %s", got, tc.times)
+			}
+			if tc.check != nil {
+				tc.check(t, r)
+			}
+		})
+	}
+}
+
+// Run a request body the way POST /simulate does and decode the response
+func simulateResponse(t *testing.T, body string) SimulationResponse {
+	t.Helper()
+	out, err := json.Marshal(simulateBody(t, body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var r SimulationResponse
+	if err := json.Unmarshal(out, &r); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func timelineString(timeline []TimelineSegment) string {
+	parts := make([]string, len(timeline))
+	for i, seg := range timeline {
+		switch {
+		case seg.Kind == "":
+			parts[i] = fmt.Sprintf("%s %d-%d", seg.ProcessID, seg.StartTime, seg.EndTime)
+		case seg.EndTime == seg.StartTime:
+			parts[i] = fmt.Sprintf("%s %d", seg.Kind, seg.StartTime)
+		default:
+			parts[i] = fmt.Sprintf("%s %s %d-%d", seg.Kind, seg.Cgroup, seg.StartTime, seg.EndTime)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+func timesString(processes []Process) string {
+	parts := make([]string, len(processes))
+	for i, p := range processes {
+		parts[i] = fmt.Sprintf("%s %d/%d", p.ID, p.WaitingTime, p.TurnaroundTime)
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,227 @@
+package main
+
+import "sort"
+
+// Metrics summarizes a finished run. Every figure is worked out from the
+// processes and the timeline alone, so it means the same thing whichever
+// algorithm produced them.
+type Metrics struct {
+	// From the first arrival to the moment the last process finishes
+	Makespan int `json:"makespan" time:"ticks"`
+
+	// Time within the makespan during which something was or was not running
+	BusyTime int `json:"busyTime" time:"ticks"`
+	IdleTime int `json:"idleTime" time:"ticks"`
+
+	// Share of the makespan the CPU was busy, from 0 to 1
+	CPUUtilization float64 `json:"cpuUtilization"`
+
+	// Processes finished per time unit
+	Throughput float64 `json:"throughput"`
+
+	// Times the CPU went from one process to a different one. Idle gaps in
+	// between do not count as a switch of their own.
+	ContextSwitches int `json:"contextSwitches"`
+
+	// Times a process lost the CPU while it still had work to do and was
+	// not about to block on I/O or a child: preempted by another process,
+	// at the end of its quantum or by a cgroup throttle
+	Preemptions int `json:"preemptions"`
+
+	// Average of turnaround time over burst time, 1 for a process that
+	// never waited
+	AverageNormalizedTurnaround float64 `json:"averageNormalizedTurnaround"`
+
+	// Min, max, percentiles and spread of the per-process times
+	Distributions *Distributions `json:"distributions,omitempty"`
+
+	// Jain's index, slowdown and starvation
+	Fairness *Fairness `json:"fairness,omitempty"`
+
+	// Ready-queue length over time and Little's law
+	Queue *QueueStats `json:"queue,omitempty"`
+}
+
+// Fill in the averages, the metrics and each process's normalized
+// turnaround and ready wait, with the histograms, starvation threshold and
+// time series req asks for. scale is the number of ticks per time unit.
+func withMetrics(response SimulationResponse, req SimulationRequest, scale int64) SimulationResponse {
+	response = withAverages(response)
+
+	var normalizedTotal float64
+	counted := 0
+	for i := range response.Processes {
+		p := &response.Processes[i]
+		if p.Abandoned || p.BurstTime <= 0 {
+			continue
+		}
+		p.NormalizedTurnaround = float64(p.TurnaroundTime) / float64(p.BurstTime)
+		normalizedTotal += p.NormalizedTurnaround
+		counted++
+	}
+
+	m := &Metrics{}
+	if counted > 0 {
+		m.AverageNormalizedTurnaround = normalizedTotal / float64(counted)
+	}
+
+	running := runningSegments(response.Timeline)
+	first, last, finished := span(response.Processes, running)
+	m.Makespan = last - first
+	m.BusyTime = busyTime(running)
+	m.IdleTime = m.Makespan - m.BusyTime
+	if m.Makespan > 0 {
+		m.CPUUtilization = float64(m.BusyTime) / float64(m.Makespan)
+		m.Throughput = float64(finished) * float64(scale) / float64(m.Makespan)
+	}
+	m.ContextSwitches = contextSwitches(running)
+	m.Distributions = distributions(response.Processes, req.Histograms)
+	states, preemptions := stateIntervals(response.Processes, running)
+	m.Preemptions = preemptions
+	m.Fairness = fairness(response.Processes, states, req.StarvationThreshold)
+	m.Queue = queueStats(response.Processes, running, states, first, last, scale, req.TimeSeries)
+
+	response.Metrics = m
+	return response
+}
+
+// Fill in the average waiting, turnaround and response times
+func withAverages(response SimulationResponse) SimulationResponse {
+	var totalWaitingTime, totalTurnaroundTime, totalResponseTime, counted int
+	for _, p := range response.Processes {
+		// Abandoned jobs never ran to completion, so they have no times
+		if p.Abandoned {
+			continue
+		}
+		totalWaitingTime += p.WaitingTime
+		totalTurnaroundTime += p.TurnaroundTime
+		totalResponseTime += p.ResponseTime
+		counted++
+	}
+
+	numProcesses := float64(counted)
+	response.AverageWaitingTime = float64(totalWaitingTime) / numProcesses
+	response.AverageTurnaroundTime = float64(totalTurnaroundTime) / numProcesses
+	response.AverageResponseTime = float64(totalResponseTime) / numProcesses
+
+	return response
+}
+
+// Timeline segments where a process actually ran, in start order. Markers
+// such as throttles and mode switches are left out.
+func runningSegments(timeline []TimelineSegment) []TimelineSegment {
+	var running []TimelineSegment
+	for _, seg := range timeline {
+		if seg.Kind == "" && seg.EndTime > seg.StartTime {
+			running = append(running, seg)
+		}
+	}
+	// Most simulators build their timeline in order already
+	inOrder := func(a, b int) bool { return running[a].StartTime < running[b].StartTime }
+	if !sort.SliceIsSorted(running, inOrder) {
+		sort.SliceStable(running, inOrder)
+	}
+	return running
+}
+
+// First arrival, the time the last process finished (or the timeline
+// ended, if later) and how many processes finished
+func span(processes []Process, running []TimelineSegment) (int, int, int) {
+	first, last, finished := 0, 0, 0
+	for i, p := range processes {
+		if i == 0 || p.ArrivalTime < first {
+			first = p.ArrivalTime
+		}
+		if p.Abandoned {
+			continue
+		}
+		finished++
+		if p.CompletionTime > last {
+			last = p.CompletionTime
+		}
+	}
+	for _, seg := range running {
+		if seg.EndTime > last {
+			last = seg.EndTime
+		}
+	}
+	if last < first {
+		last = first
+	}
+	return first, last, finished
+}
+
+// Total time covered by at least one running segment. Segments only
+// overlap when several processors run at once, as in batch runs.
+func busyTime(running []TimelineSegment) int {
+	busy := 0
+	coveredUntil := 0
+	for i, seg := range running {
+		start := seg.StartTime
+		if i > 0 && start < coveredUntil {
+			start = coveredUntil
+		}
+		if seg.EndTime > start {
+			busy += seg.EndTime - start
+		}
+		if i == 0 || seg.EndTime > coveredUntil {
+			coveredUntil = seg.EndTime
+		}
+	}
+	return busy
+}
+
+// Count the times the CPU changed hands. A segment that overlaps the one
+// before it runs on another processor, so it is not a switch.
+func contextSwitches(running []TimelineSegment) int {
+	switches := 0
+	for i := 1; i < len(running); i++ {
+		if running[i].StartTime < running[i-1].EndTime {
+			continue
+		}
+		if running[i].ProcessID != running[i-1].ProcessID {
+			switches++
+		}
+	}
+	return switches
+}
+
+// Whether an entity blocks once it has run for executed time units: at one
+// of its I/O offsets or at a fork it waits on
+func (e schedEntity) blocksAt(executed int) bool {
+	for _, io := range e.ioBursts {
+		if io.Offset == executed {
+			return true
+		}
+	}
+	for _, s := range e.spawns {
+		if s.Wait && s.Offset == executed {
+			return true
+		}
+	}
+	return false
+}
+
+// schedEntity is something the timeline shows running: a process, or one
+// thread of a multithreaded process, which appears as "process:thread"
+type schedEntity struct {
+	id       string
+	owner    int // Index of the process it belongs to
+	arrival  int
+	ioBursts []IOBurst
+	spawns   []Spawn
+}
+
+func schedEntities(processes []Process) []schedEntity {
+	var entities []schedEntity
+	for i, p := range processes {
+		if len(p.Threads) == 0 {
+			entities = append(entities, schedEntity{id: p.ID, owner: i, arrival: p.ArrivalTime, ioBursts: p.IOBursts, spawns: p.Spawns})
+			continue
+		}
+		for _, t := range p.Threads {
+			entities = append(entities, schedEntity{id: p.ID + ":" + t.ID, owner: i, arrival: t.ArrivalTime, ioBursts: t.IOBursts})
+		}
+	}
+	return entities
+}
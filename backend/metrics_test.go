@@ -0,0 +1,34 @@
+package main
+
+import "testing"
+
+// Stopping with work left counts as a preemption unless the process
+// blocked there or carried straight on
+func TestPreemptions(t *testing.T) {
+	processes := []Process{
+		{ID: "P1", BurstTime: 4, IOBursts: []IOBurst{{Offset: 1, Duration: 2}}},
+		{ID: "P2", BurstTime: 3},
+		{ID: "P3", BurstTime: 2},
+	}
+	running := []TimelineSegment{
+		{ProcessID: "P1", StartTime: 0, EndTime: 1}, // Blocks on I/O
+		{ProcessID: "P2", StartTime: 1, EndTime: 2}, // Preempted
+		{ProcessID: "P3", StartTime: 2, EndTime: 3},
+		{ProcessID: "P3", StartTime: 3, EndTime: 4}, // Carried straight on
+		{ProcessID: "P2", StartTime: 4, EndTime: 6},
+		{ProcessID: "P1", StartTime: 6, EndTime: 9},
+	}
+	if _, got := stateIntervals(processes, running); got != 1 {
+		t.Errorf("got %d preemptions, want 1", got)
+	}
+}
+
+// Metrics for a run of a million processes
+func BenchmarkMetrics(b *testing.B) {
+	req := SimulationRequest{Algorithm: "FCFS"}
+	response := runFCFS(benchmarkProcesses(), defaultTieBreaker)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		withMetrics(response, req, 1)
+	}
+}
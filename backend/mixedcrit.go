@@ -0,0 +1,90 @@
+package main
+
+import "fmt"
+
+// MixedCriticalityReport describes the mode switch of an AMC or EDF-VD run
+type MixedCriticalityReport struct {
+	ModeSwitchTime        *int     `json:"modeSwitchTime,omitempty" time:"ticks"` // Unset if the system stayed in LO mode
+	AbandonedJobs         []string `json:"abandonedJobs,omitempty"`
+	VirtualDeadlineFactor float64  `json:"virtualDeadlineFactor,omitempty"` // EDF-VD only
+}
+
+func isHICriticality(p Process) bool {
+	return p.Criticality == "HI"
+}
+
+// LO-level WCET of a task, defaulting to its burst time
+func loWCET(p Process) int {
+	if p.WCETLo > 0 {
+		return p.WCETLo
+	}
+	return p.BurstTime
+}
+
+// Check the criticality settings of a real-time workload. A job may not run
+// longer than its task's HI-level WCET.
+func validateCriticality(req SimulationRequest) []FieldError {
+	var errs []FieldError
+	switch req.LOCriticalityMode {
+	case "", "drop", "degrade":
+	default:
+		errs = append(errs, fieldError("loCriticalityMode", codeUnknown, "Unknown LO criticality mode"))
+	}
+	for i, p := range req.Processes {
+		path := fmt.Sprintf("processes[%d]", i)
+		switch p.Criticality {
+		case "", "LO", "HI":
+		default:
+			errs = append(errs, fieldError(path+".criticality", codeUnknown, "Criticality must be LO or HI"))
+		}
+		if p.WCETLo < 0 {
+			errs = append(errs, fieldError(path+".wcetLo", codeNegative, "WCETs cannot be negative"))
+		}
+		if p.WCETHi < 0 {
+			errs = append(errs, fieldError(path+".wcetHi", codeNegative, "WCETs cannot be negative"))
+		}
+		if p.WCETHi > 0 && p.WCETLo > p.WCETHi {
+			errs = append(errs, fieldError(path+".wcetLo", codeOutOfRange, "LO WCET cannot exceed HI WCET"))
+		}
+		if isHICriticality(p) && p.WCETHi > 0 && p.BurstTime > p.WCETHi {
+			errs = append(errs, fieldError(path+".burstTime", codeOutOfRange, "Burst time cannot exceed HI WCET"))
+		}
+	}
+	return errs
+}
+
+// EDF-VD deadline scaling factor x = U_HI(LO) / (1 - U_LO(LO)), where the
+// utilizations use LO-level WCETs. HI tasks get virtual deadlines x*D in LO
+// mode so they finish early enough to absorb an overrun. Falls back to 1
+// (plain EDF) when the LO tasks alone saturate the CPU.
+func edfVDFactor(processes []Process) float64 {
+	var uLO, uHI float64
+	for _, p := range processes {
+		if p.Period <= 0 {
+			continue
+		}
+		u := float64(loWCET(p)) / float64(p.Period)
+		if isHICriticality(p) {
+			uHI += u
+		} else {
+			uLO += u
+		}
+	}
+	if uLO >= 1 {
+		return 1
+	}
+	x := uHI / (1 - uLO)
+	if x <= 0 || x > 1 {
+		return 1
+	}
+	return x
+}
+
+// Relative virtual deadline x*D, at least one time unit
+func virtualDeadline(deadline int, factor float64) int {
+	vd := int(factor * float64(deadline))
+	if vd < 1 {
+		vd = 1
+	}
+	return vd
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestMixedCriticalitySchedules(t *testing.T) {
+	report := func(switchAt int, abandoned ...string) func(t *testing.T, r SimulationResponse) {
+		return func(t *testing.T, r SimulationResponse) {
+			mc := r.MixedCriticality
+			if mc == nil {
+				t.Fatal("no mixed-criticality report")
+			}
+			got := -1
+			if mc.ModeSwitchTime != nil {
+				got = *mc.ModeSwitchTime
+			}
+			if got != switchAt {
+				t.Errorf("mode switch at %d, want %d", got, switchAt)
+			}
+			if len(mc.AbandonedJobs) > 0 || len(abandoned) > 0 {
+				if !reflect.DeepEqual(mc.AbandonedJobs, abandoned) {
+					t.Errorf("abandoned %v, want %v", mc.AbandonedJobs, abandoned)
+				}
+			}
+			for _, p := range r.Processes {
+				if p.Abandoned != slices.Contains(abandoned, p.ID) {
+					t.Errorf("%s abandoned = %v", p.ID, p.Abandoned)
+				}
+			}
+		}
+	}
+	// H overruns its LO WCET of 2 and needs its HI WCET of 4
+	tasks := `[{"id": "H", "burstTime": 4, "period": 10, "criticality": "HI", "wcetLo": 2, "wcetHi": 4}, {"id": "L", "burstTime": 2, "period": 5}]`
+	runScheduleCases(t, []scheduleCase{
+		{
+			// L#1 is released in HI mode and dropped straight away
+			name:     "amc drop",
+			body:     `{"algorithm": "AMC", "horizon": 10, "processes": ` + tasks + `}`,
+			timeline: "L#0 0-2, H#0 2-4, mode-switch 4, H#0 4-6",
+			times:    "H#0 2/6, L#0 0/2, L#1 0/0",
+			check:    report(4, "L#1"),
+		},
+		{
+			name:     "amc degrade",
+			body:     `{"algorithm": "AMC", "horizon": 10, "loCriticalityMode": "degrade", "processes": ` + tasks + `}`,
+			timeline: "L#0 0-2, H#0 2-4, mode-switch 4, H#0 4-6, L#1 6-8",
+			times:    "H#0 2/6, L#0 0/2, L#1 1/3",
+			check:    report(4),
+		},
+		{
+			// H's virtual deadline of 10/3 puts it ahead of L#0; L#0 has
+			// waited 2 when the switch drops it
+			name:     "edf-vd",
+			body:     `{"algorithm": "EDF-VD", "horizon": 10, "processes": ` + tasks + `}`,
+			timeline: "H#0 0-2, mode-switch 2, H#0 2-4",
+			times:    "H#0 0/4, L#0 2/0, L#1 0/0",
+			check: func(t *testing.T, r SimulationResponse) {
+				report(2, "L#0", "L#1")(t, r)
+				if x := r.MixedCriticality.VirtualDeadlineFactor; x < 0.333 || x > 0.334 {
+					t.Errorf("virtual deadline factor %v, want 1/3", x)
+				}
+			},
+		},
+		{
+			name:     "edf-vd within LO WCET",
+			body:     `{"algorithm": "EDF-VD", "horizon": 10, "processes": [{"id": "H", "burstTime": 2, "period": 10, "criticality": "HI", "wcetLo": 2, "wcetHi": 4}, {"id": "L", "burstTime": 2, "period": 5}]}`,
+			timeline: "H#0 0-2, L#0 2-4, L#1 5-7",
+			times:    "H#0 0/2, L#0 2/4, L#1 0/2",
+			check:    report(-1),
+		},
+	})
+}
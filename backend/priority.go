@@ -0,0 +1,22 @@
+package main
+
+// Priority numbers mean different things in different textbooks: in some,
+// priority 1 is the most urgent (as in this simulator by default); in
+// others, bigger numbers win. PriorityOrder picks the reading, and every
+// algorithm that looks at Priority goes through priorityRank.
+
+func isValidPriorityOrder(order string) bool {
+	switch order {
+	case "", "lower-first", "higher-first":
+		return true
+	}
+	return false
+}
+
+// Rank of a process's priority, where a smaller rank runs first
+func priorityRank(p Process, higherFirst bool) int {
+	if higherFirst {
+		return -p.Priority
+	}
+	return p.Priority
+}
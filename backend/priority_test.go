@@ -0,0 +1,36 @@
+package main
+
+import "testing"
+
+func TestPriorityRRSchedules(t *testing.T) {
+	// A and B share the top band and C sits alone below them
+	bands := `"processes": [{"id": "A", "burstTime": 4, "priority": 1}, {"id": "B", "burstTime": 3, "priority": 1}, {"id": "C", "burstTime": 2, "priority": 2}]}`
+	runScheduleCases(t, []scheduleCase{
+		{
+			name:     "round robin within a band",
+			body:     `{"algorithm": "PriorityRR", "timeQuantum": 2, ` + bands,
+			timeline: "A 0-2, B 2-4, A 4-6, B 6-7, C 7-9",
+			times:    "A 2/6, B 4/7, C 7/9",
+		},
+		{
+			// Plain preemptive priority keeps running the first of the band
+			name:     "preemptive priority",
+			body:     `{"algorithm": "Priority", "isPreemptive": true, ` + bands,
+			timeline: "A 0-4, B 4-7, C 7-9",
+			times:    "A 0/4, B 4/7, C 7/9",
+		},
+		{
+			name:     "higher first",
+			body:     `{"algorithm": "PriorityRR", "timeQuantum": 2, "priorityOrder": "higher-first", ` + bands,
+			timeline: "C 0-2, A 2-4, B 4-6, A 6-8, B 8-9",
+			times:    "A 4/8, B 6/9, C 0/2",
+		},
+		{
+			// C preempts A mid-quantum; A rejoins its band behind B
+			name:     "preempted by a higher band",
+			body:     `{"algorithm": "PriorityRR", "timeQuantum": 3, "processes": [{"id": "A", "burstTime": 5, "priority": 2}, {"id": "B", "burstTime": 2, "priority": 2}, {"id": "C", "arrivalTime": 1, "burstTime": 2, "priority": 1}]}`,
+			timeline: "A 0-1, C 1-3, B 3-5, A 5-9",
+			times:    "A 4/9, B 3/5, C 0/2",
+		},
+	})
+}
@@ -0,0 +1,97 @@
+package main
+
+// ProcessTreeNode is one process in the fork tree of a simulation, with the
+// metrics it ended up with and the children it forked.
+type ProcessTreeNode struct {
+	Process  Process           `json:"process"`
+	Children []ProcessTreeNode `json:"children,omitempty"`
+}
+
+// Check whether any process in the workload forks children
+func hasSpawns(processes []Process) bool {
+	for _, p := range processes {
+		if len(p.Spawns) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func isValidInheritPolicy(policy string) bool {
+	switch policy {
+	case "", "inherit", "explicit", "reset-on-fork":
+		return true
+	}
+	return false
+}
+
+// Work out the priority, nice value and scheduling policy of a forked child.
+//
+//   - "inherit" (default): the child copies its parent's values
+//   - "explicit": the child keeps the values given in its spawn
+//   - "reset-on-fork": like SCHED_RESET_ON_FORK, the child copies the parent's
+//     values but a real-time policy reverts to SCHED_OTHER and a negative
+//     (boosted) nice value is reset to 0
+func inheritFrom(parent, child Process, policy string) Process {
+	switch policy {
+	case "explicit":
+	case "reset-on-fork":
+		child.Priority = parent.Priority
+		child.Nice = parent.Nice
+		child.Policy = parent.Policy
+		child.RTPriority = parent.RTPriority
+		if isRealtime(child) {
+			child.Policy = schedOther
+			child.RTPriority = 0
+		}
+		if child.Nice < 0 {
+			child.Nice = 0
+		}
+	default:
+		child.Priority = parent.Priority
+		child.Nice = parent.Nice
+		child.Policy = parent.Policy
+		child.RTPriority = parent.RTPriority
+	}
+	return child
+}
+
+// Schedule a workload whose processes fork children part-way through their
+// bursts, using the step engine so children join the ready queue at the
+// moment they are created
+func runProcessTree(req SimulationRequest) SimulationResponse {
+	e := newEngine(req)
+	e.run()
+
+	response := e.response()
+	response.ProcessTree = buildProcessTree(e.procs, e.parent)
+	return response
+}
+
+// Arrange processes into trees using each process's parent index
+func buildProcessTree(procs []Process, parent []int) []ProcessTreeNode {
+	children := make([][]int, len(procs))
+	var roots []int
+	for i, p := range parent {
+		if p == -1 {
+			roots = append(roots, i)
+		} else {
+			children[p] = append(children[p], i)
+		}
+	}
+
+	var build func(i int) ProcessTreeNode
+	build = func(i int) ProcessTreeNode {
+		node := ProcessTreeNode{Process: procs[i]}
+		for _, c := range children[i] {
+			node.Children = append(node.Children, build(c))
+		}
+		return node
+	}
+
+	tree := make([]ProcessTreeNode, 0, len(roots))
+	for _, r := range roots {
+		tree = append(tree, build(r))
+	}
+	return tree
+}
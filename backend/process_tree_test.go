@@ -0,0 +1,30 @@
+package main
+
+import "testing"
+
+func TestForkSchedules(t *testing.T) {
+	fork := func(wait string) string {
+		return `{"algorithm": "FCFS", "processes": [{"id": "P", "burstTime": 4, "spawns": [{"offset": 1, ` + wait + `"child": {"id": "C", "burstTime": 3}}]}, {"id": "Q", "burstTime": 2}]}`
+	}
+	runScheduleCases(t, []scheduleCase{
+		{
+			// P blocks from the fork until C exits, so Q and C run first and
+			// P's blocked time is not counted as waiting
+			name:     "fork and wait",
+			body:     fork(`"wait": true, `),
+			timeline: "P 0-1, Q 1-3, C 3-6, P 6-9",
+			times:    "P 0/9, Q 1/3, C 2/5",
+			check: func(t *testing.T, r SimulationResponse) {
+				if len(r.ProcessTree) != 2 || len(r.ProcessTree[0].Children) != 1 || r.ProcessTree[0].Children[0].Process.ID != "C" {
+					t.Errorf("process tree %+v, want C under P", r.ProcessTree)
+				}
+			},
+		},
+		{
+			name:     "fork without wait",
+			body:     fork(""),
+			timeline: "P 0-4, Q 4-6, C 6-9",
+			times:    "P 0/4, Q 4/6, C 5/8",
+		},
+	})
+}
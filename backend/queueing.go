@@ -0,0 +1,266 @@
+package main
+
+import (
+	"cmp"
+	"slices"
+)
+
+// Queueing view of a run: how many processes were ready, running and
+// blocked at every moment, rebuilt from the processes and the timeline so
+// it works for every algorithm.
+
+// QueueStats summarizes the system state over the makespan
+type QueueStats struct {
+	// Time-averaged number of processes in each state
+	AverageReadyQueue float64 `json:"averageReadyQueue"`
+	AverageRunning    float64 `json:"averageRunning"`
+	AverageBlocked    float64 `json:"averageBlocked"`
+
+	MaxReadyQueue int `json:"maxReadyQueue"`
+
+	LittlesLaw *LittlesLaw `json:"littlesLaw,omitempty"`
+
+	// Event-exact state counts, one point per change, when the request
+	// asks for TimeSeries
+	Series []StatePoint `json:"series,omitempty"`
+}
+
+// LittlesLaw checks L = lambda * W for the ready queue: the time-averaged
+// queue length against the arrival rate times the mean waiting time the
+// algorithm reported. A large relative error means waiting times were
+// counted differently from time spent in the ready queue.
+type LittlesLaw struct {
+	ArrivalRate   float64 `json:"arrivalRate"` // Per time unit
+	MeanWait      float64 `json:"meanWait" time:"average"`
+	Predicted     float64 `json:"predictedQueueLength"`
+	Measured      float64 `json:"measuredQueueLength"`
+	RelativeError float64 `json:"relativeError"`
+}
+
+// StatePoint holds the state counts from Time until the next point
+type StatePoint struct {
+	Time    int `json:"time" time:"ticks"`
+	Ready   int `json:"ready"`
+	Running int `json:"running"`
+	Blocked int `json:"blocked"`
+}
+
+type interval struct {
+	start, end int
+}
+
+// entityStates holds when one schedulable entity was ready and when it was
+// blocked; it was running during its timeline segments
+type entityStates struct {
+	id      string
+	owner   int // Index of the process it belongs to
+	ready   []interval
+	blocked []interval
+}
+
+// Ready and blocked intervals of every schedulable entity, in one pass
+// over the running segments that also counts preemptions: the times an
+// entity stopped running with work left without blocking, and did not just
+// carry on straight away. An entity is ready from arrival until it runs and
+// again whenever it stops running with work left, except while blocked on
+// I/O or on children it forked and waits for. Abandoned jobs are left out
+// of the intervals, since when they left is unknown.
+func stateIntervals(processes []Process, running []TimelineSegment) ([]entityStates, int) {
+	entities := schedEntities(processes)
+	segments := segmentsByEntity(entities, running)
+	var children map[string][]int
+	for i, p := range processes {
+		if p.ParentID != "" {
+			if children == nil {
+				children = make(map[string][]int)
+			}
+			children[p.ParentID] = append(children[p.ParentID], i)
+		}
+	}
+
+	states := make([]entityStates, 0, len(entities))
+	preemptions := 0
+	for k, e := range entities {
+		// A segment followed later by another one of the same entity ended
+		// in a preemption unless the entity blocked there
+		executed := 0
+		for j, seg := range segments[k] {
+			executed += seg.EndTime - seg.StartTime
+			if j+1 < len(segments[k]) && segments[k][j+1].StartTime != seg.EndTime && !e.blocksAt(executed) {
+				preemptions++
+			}
+		}
+
+		if processes[e.owner].Abandoned {
+			continue
+		}
+		st := entityStates{id: e.id, owner: e.owner}
+		executed = 0
+
+		// Time the entity becomes ready again after reaching a blocking
+		// point at time now
+		unblocked := func(now int) int {
+			ready := now
+			for _, io := range e.ioBursts {
+				if io.Offset == executed && now+io.Duration > ready {
+					ready = now + io.Duration
+				}
+			}
+			for _, s := range e.spawns {
+				if !s.Wait || s.Offset != executed {
+					continue
+				}
+				// Children forked now are the ones this fork created
+				for _, c := range children[e.id] {
+					if processes[c].ArrivalTime == now && processes[c].CompletionTime > ready {
+						ready = processes[c].CompletionTime
+					}
+				}
+			}
+			return ready
+		}
+		block := func(now int) int {
+			ready := unblocked(now)
+			if ready > now {
+				st.blocked = append(st.blocked, interval{now, ready})
+			}
+			return ready
+		}
+
+		readySince := block(e.arrival)
+		for _, seg := range segments[k] {
+			if seg.StartTime > readySince {
+				st.ready = append(st.ready, interval{readySince, seg.StartTime})
+			}
+			executed += seg.EndTime - seg.StartTime
+			readySince = block(seg.EndTime)
+		}
+		states = append(states, st)
+	}
+	return states, preemptions
+}
+
+// Running segments of each entity, in start order. Segments of IDs that
+// are not an entity are left out.
+func segmentsByEntity(entities []schedEntity, running []TimelineSegment) [][]TimelineSegment {
+	index := make(map[string]int, len(entities))
+	for k, e := range entities {
+		index[e.id] = k
+	}
+
+	// Count first, then share one backing array, so a run with a million
+	// processes does not allocate a million slices one append at a time
+	owner := make([]int, len(running))
+	counts := make([]int, len(entities))
+	for i, seg := range running {
+		k, ok := index[seg.ProcessID]
+		if !ok {
+			k = -1
+		} else {
+			counts[k]++
+		}
+		owner[i] = k
+	}
+	backing := make([]TimelineSegment, 0, len(running))
+	segments := make([][]TimelineSegment, len(entities))
+	for k, n := range counts {
+		segments[k] = backing[len(backing) : len(backing) : len(backing)+n]
+		backing = backing[:len(backing)+n]
+	}
+	for i, seg := range running {
+		if k := owner[i]; k != -1 {
+			segments[k] = append(segments[k], seg)
+		}
+	}
+	return segments
+}
+
+// Queue statistics over [first, last], with the event-exact series if
+// asked for. scale is the number of ticks per time unit.
+func queueStats(processes []Process, running []TimelineSegment, states []entityStates, first, last int, scale int64, series bool) *QueueStats {
+	q := &QueueStats{}
+	makespan := last - first
+	if makespan <= 0 {
+		return q
+	}
+
+	// State changes: +1 when an entity enters a state, -1 when it leaves.
+	// Kept small, since a big run sorts millions of them.
+	type change struct {
+		time                    int
+		ready, running, blocked int8
+	}
+	changes := make([]change, 0, 2*len(running))
+	readyTime, blockedTime, runningTime := 0, 0, 0
+	for _, st := range states {
+		for _, iv := range st.ready {
+			changes = append(changes, change{time: iv.start, ready: 1}, change{time: iv.end, ready: -1})
+			readyTime += iv.end - iv.start
+		}
+		for _, iv := range st.blocked {
+			changes = append(changes, change{time: iv.start, blocked: 1}, change{time: iv.end, blocked: -1})
+			blockedTime += iv.end - iv.start
+		}
+	}
+	for _, seg := range running {
+		changes = append(changes, change{time: seg.StartTime, running: 1}, change{time: seg.EndTime, running: -1})
+		runningTime += seg.EndTime - seg.StartTime
+	}
+	slices.SortFunc(changes, func(a, b change) int { return cmp.Compare(a.time, b.time) })
+
+	cur := StatePoint{Time: first}
+	for i := 0; i < len(changes); {
+		// Apply every change at this instant before recording the state
+		t := changes[i].time
+		for ; i < len(changes) && changes[i].time == t; i++ {
+			cur.Ready += int(changes[i].ready)
+			cur.Running += int(changes[i].running)
+			cur.Blocked += int(changes[i].blocked)
+		}
+		cur.Time = t
+		if cur.Ready > q.MaxReadyQueue {
+			q.MaxReadyQueue = cur.Ready
+		}
+		if series {
+			// Only keep points where something changed
+			n := len(q.Series)
+			if n == 0 || q.Series[n-1].Ready != cur.Ready || q.Series[n-1].Running != cur.Running || q.Series[n-1].Blocked != cur.Blocked {
+				q.Series = append(q.Series, cur)
+			}
+		}
+	}
+
+	q.AverageReadyQueue = float64(readyTime) / float64(makespan)
+	q.AverageRunning = float64(runningTime) / float64(makespan)
+	q.AverageBlocked = float64(blockedTime) / float64(makespan)
+
+	// Little's law over the same window, counting threads as the entities
+	// that queue
+	arrivals, totalWait := len(states), 0
+	for _, p := range processes {
+		if !p.Abandoned {
+			totalWait += p.WaitingTime
+		}
+	}
+	if arrivals > 0 {
+		law := &LittlesLaw{
+			ArrivalRate: float64(arrivals) * float64(scale) / float64(makespan),
+			MeanWait:    float64(totalWait) / float64(arrivals),
+			Measured:    q.AverageReadyQueue,
+		}
+		// lambda * W with the arrival count cancelled out, so an exact
+		// match is not lost to rounding
+		law.Predicted = float64(totalWait) / float64(makespan)
+		switch {
+		case law.Measured > 0:
+			law.RelativeError = (law.Predicted - law.Measured) / law.Measured
+			if law.RelativeError < 0 {
+				law.RelativeError = -law.RelativeError
+			}
+		case law.Predicted > 0:
+			law.RelativeError = 1
+		}
+		q.LittlesLaw = law
+	}
+	return q
+}
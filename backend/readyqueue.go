@@ -0,0 +1,238 @@
+package main
+
+import "sort"
+
+// Data structures that keep the run* functions fast on large workloads
+// (hundreds of thousands of processes): an arrival index, so finding who
+// has arrived never rescans the whole process list, and a heap-ordered
+// ready queue, so picking the next process is O(log n).
+
+// Process indices in arrival order, simultaneous arrivals in tie-breaker
+// order given by rank
+func arrivalOrder(procs []Process, rank []int) []int {
+	order := make([]int, len(procs))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(a, b int) bool {
+		pa, pb := procs[order[a]], procs[order[b]]
+		if pa.ArrivalTime != pb.ArrivalTime {
+			return pa.ArrivalTime < pb.ArrivalTime
+		}
+		return rank[order[a]] < rank[order[b]]
+	})
+	return order
+}
+
+// readyHeap is a ready queue of processes ordered by key, smallest first,
+// with ties in tie-breaker order. Entries carry their own sort key so that
+// comparisons never touch the (large) Process structs; keys must not change
+// while a process is in the heap, which holds for burst time and priority,
+// and for remaining time because only the running process's goes down.
+type readyHeap struct {
+	items []readyEntry
+}
+
+type readyEntry struct {
+	key  int
+	rank int // Position in tie-breaker order
+	proc int // Index into procs
+}
+
+func (a readyEntry) before(b readyEntry) bool {
+	if a.key != b.key {
+		return a.key < b.key
+	}
+	return a.rank < b.rank
+}
+
+func (h *readyHeap) Len() int { return len(h.items) }
+
+func (h *readyHeap) top() readyEntry { return h.items[0] }
+
+func (h *readyHeap) push(e readyEntry) {
+	h.items = append(h.items, e)
+	// Sift up
+	k := len(h.items) - 1
+	for k > 0 {
+		parent := (k - 1) / 2
+		if !h.items[k].before(h.items[parent]) {
+			break
+		}
+		h.items[k], h.items[parent] = h.items[parent], h.items[k]
+		k = parent
+	}
+}
+
+func (h *readyHeap) pop() readyEntry {
+	top := h.items[0]
+	n := len(h.items) - 1
+	h.items[0] = h.items[n]
+	h.items = h.items[:n]
+
+	// Sift down
+	k := 0
+	for {
+		child := 2*k + 1
+		if child >= n {
+			break
+		}
+		if child+1 < n && h.items[child+1].before(h.items[child]) {
+			child++
+		}
+		if !h.items[child].before(h.items[k]) {
+			break
+		}
+		h.items[k], h.items[child] = h.items[child], h.items[k]
+		k = child
+	}
+	return top
+}
+
+// Run a non-preemptive algorithm that always picks the ready process with
+// the smallest key (SJF, non-preemptive Priority). Processes come back in
+// input order, along with the order in which they ran.
+func runNonPreemptiveByKey(processes []Process, tb tieBreaker, key func(p *Process) int) (SimulationResponse, []int) {
+	// Make a copy of processes
+	procs := make([]Process, len(processes))
+	copy(procs, processes)
+
+	var timeline []TimelineSegment
+	rank := tb.ranks(procs)
+	order := arrivalOrder(procs, rank)
+	ready := &readyHeap{}
+	entry := func(i int) readyEntry { return readyEntry{key: key(&procs[i]), rank: rank[i], proc: i} }
+	next := 0 // Next process in arrival order that has not arrived yet
+	currentTime := procs[order[0]].ArrivalTime
+	ran := make([]int, 0, len(procs))
+
+	for done := 0; done < len(procs); done++ {
+		// Everyone who has arrived joins the ready queue; if nobody is
+		// ready, advance time to the next arrival
+		if ready.Len() == 0 && procs[order[next]].ArrivalTime > currentTime {
+			currentTime = procs[order[next]].ArrivalTime
+		}
+		for next < len(order) && procs[order[next]].ArrivalTime <= currentTime {
+			ready.push(entry(order[next]))
+			next++
+		}
+
+		selectedIdx := ready.pop().proc
+		ran = append(ran, selectedIdx)
+		p := &procs[selectedIdx]
+
+		// Set start time and response time
+		p.StartTime = currentTime
+		p.ResponseTime = p.StartTime - p.ArrivalTime
+		p.IsStarted = true
+
+		// Add to timeline
+		if p.RemainingTime > 0 {
+			timeline = append(timeline, TimelineSegment{
+				ProcessID: p.ID,
+				StartTime: currentTime,
+				EndTime:   currentTime + p.RemainingTime,
+			})
+		}
+
+		// Run to completion and set completion time and metrics
+		currentTime += p.RemainingTime
+		p.CompletionTime = currentTime
+		p.TurnaroundTime = p.CompletionTime - p.ArrivalTime
+		p.WaitingTime = p.TurnaroundTime - p.BurstTime
+		p.RemainingTime = 0
+	}
+
+	return SimulationResponse{
+		Processes: procs,
+		Timeline:  timeline,
+	}, ran
+}
+
+// Run a preemptive algorithm where the ready process with the smallest key
+// always has the CPU (SRTF, preemptive Priority). Decisions are only needed
+// when a process arrives or finishes, so time jumps between those events.
+func runPreemptiveByKey(processes []Process, tb tieBreaker, key func(p *Process) int) SimulationResponse {
+	// Make a copy of processes
+	procs := make([]Process, len(processes))
+	copy(procs, processes)
+	for i := range procs {
+		procs[i].IsStarted = false
+	}
+
+	var timeline []TimelineSegment
+	rank := tb.ranks(procs)
+	order := arrivalOrder(procs, rank)
+	ready := &readyHeap{}
+	entry := func(i int) readyEntry { return readyEntry{key: key(&procs[i]), rank: rank[i], proc: i} }
+	next := 0 // Next process in arrival order that has not arrived yet
+	currentTime := procs[order[0]].ArrivalTime
+
+	// Track the currently running process
+	currentProcess := -1
+	currentSegmentStart := 0
+	closeSegment := func() {
+		if currentTime > currentSegmentStart {
+			timeline = append(timeline, TimelineSegment{
+				ProcessID: procs[currentProcess].ID,
+				StartTime: currentSegmentStart,
+				EndTime:   currentTime,
+			})
+		}
+	}
+
+	for done := 0; done < len(procs); {
+		// If nothing is running or ready, advance time to the next arrival
+		if currentProcess == -1 && ready.Len() == 0 && procs[order[next]].ArrivalTime > currentTime {
+			currentTime = procs[order[next]].ArrivalTime
+		}
+		for next < len(order) && procs[order[next]].ArrivalTime <= currentTime {
+			ready.push(entry(order[next]))
+			next++
+		}
+
+		// The best ready process takes over if its key beats the running
+		// one's; the tie-breaker only orders the ready queue
+		if currentProcess != -1 && ready.Len() > 0 && ready.top().key < key(&procs[currentProcess]) {
+			closeSegment()
+			ready.push(entry(currentProcess))
+			currentProcess = -1
+		}
+		if currentProcess == -1 {
+			currentProcess = ready.pop().proc
+			currentSegmentStart = currentTime
+
+			// If this is the first time this process gets CPU, record response time
+			if !procs[currentProcess].IsStarted {
+				procs[currentProcess].StartTime = currentTime
+				procs[currentProcess].ResponseTime = currentTime - procs[currentProcess].ArrivalTime
+				procs[currentProcess].IsStarted = true
+			}
+		}
+
+		// Run until the process finishes or the next arrival, which might
+		// preempt it
+		p := &procs[currentProcess]
+		timeSlice := p.RemainingTime
+		if next < len(order) && procs[order[next]].ArrivalTime-currentTime < timeSlice {
+			timeSlice = procs[order[next]].ArrivalTime - currentTime
+		}
+		currentTime += timeSlice
+		p.RemainingTime -= timeSlice
+
+		// If process completes, set completion time and calculate metrics
+		if p.RemainingTime == 0 {
+			closeSegment()
+			p.CompletionTime = currentTime
+			p.TurnaroundTime = p.CompletionTime - p.ArrivalTime
+			p.WaitingTime = p.TurnaroundTime - p.BurstTime
+			currentProcess = -1
+			done++
+		}
+	}
+
+	return SimulationResponse{
+		Processes: procs,
+		Timeline:  timeline,
+	}
+}
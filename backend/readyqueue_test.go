@@ -0,0 +1,328 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Golden results recorded from the run* functions as they were before the
+// arrival index and heap ready queues, for FCFS, SJF, SRTF, RR and
+// Priority on small random workloads full of ties. The heap versions must
+// match them exactly. SRTF and preemptive Priority were re-recorded once
+// a process tied with the running one stopped preempting it.
+type goldenWorkload struct {
+	TieBreaker []string        `json:"tieBreaker,omitempty"`
+	Processes  []goldenProcess `json:"processes"`
+	Runs       []goldenRun     `json:"runs"`
+}
+
+type goldenProcess struct {
+	ID          string `json:"id"`
+	ArrivalTime int    `json:"arrivalTime"`
+	BurstTime   int    `json:"burstTime"`
+	Priority    int    `json:"priority"`
+}
+
+type goldenRun struct {
+	Algorithm string            `json:"algorithm"`
+	Quantum   int               `json:"timeQuantum,omitempty"`
+	Results   []goldenResult    `json:"results"`
+	Timeline  []TimelineSegment `json:"timeline"`
+}
+
+type goldenResult struct {
+	ID             string `json:"id"`
+	StartTime      int    `json:"startTime"`
+	CompletionTime int    `json:"completionTime"`
+	WaitingTime    int    `json:"waitingTime"`
+	ResponseTime   int    `json:"responseTime"`
+}
+
+// Run one of the golden algorithms on a fresh copy of the workload
+func runGolden(w goldenWorkload, run goldenRun) SimulationResponse {
+	procs := make([]Process, len(w.Processes))
+	for i, p := range w.Processes {
+		procs[i] = Process{ID: p.ID, ArrivalTime: p.ArrivalTime, BurstTime: p.BurstTime, RemainingTime: p.BurstTime, Priority: p.Priority}
+	}
+	tb := defaultTieBreaker
+	if len(w.TieBreaker) > 0 {
+		tb = tieBreaker(w.TieBreaker)
+	}
+	switch run.Algorithm {
+	case "FCFS":
+		return runFCFS(procs, tb)
+	case "SJF":
+		return runSJF(procs, tb)
+	case "SRTF":
+		return runSRTF(procs, tb)
+	case "RR":
+		return runRoundRobin(procs, run.Quantum, "", false, tb)
+	case "RR/preempted-first":
+		return runRoundRobin(procs, run.Quantum, "", true, tb)
+	case "DynamicRR":
+		return runRoundRobin(procs, run.Quantum, "median", false, tb)
+	case "Priority":
+		return runNonPreemptivePriority(procs, false, tb)
+	case "PreemptivePriority":
+		return runPreemptivePriority(procs, false, tb)
+	case "PreemptivePriority/higher-first":
+		return runPreemptivePriority(procs, true, tb)
+	}
+	panic("unknown golden algorithm " + run.Algorithm)
+}
+
+func TestGoldenResults(t *testing.T) {
+	data, err := os.ReadFile("testdata/runqueue_golden.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var workloads []goldenWorkload
+	if err := json.Unmarshal(data, &workloads); err != nil {
+		t.Fatal(err)
+	}
+	for w, workload := range workloads {
+		for _, run := range workload.Runs {
+			got := runGolden(workload, run)
+			var results []goldenResult
+			for _, p := range got.Processes {
+				results = append(results, goldenResult{p.ID, p.StartTime, p.CompletionTime, p.WaitingTime, p.ResponseTime})
+			}
+			if !reflect.DeepEqual(results, run.Results) {
+				t.Errorf("workload %d, %s: results\n got %v\nwant %v", w, run.Algorithm, results, run.Results)
+			}
+			if !reflect.DeepEqual(got.Timeline, run.Timeline) {
+				t.Errorf("workload %d, %s: timeline\n got %v\nwant %v", w, run.Algorithm, got.Timeline, run.Timeline)
+			}
+		}
+	}
+}
+
+// SRTF ties and simultaneous RR arrivals are where a heap most easily
+// drifts from the scans it replaced. The golden workloads are full of both;
+// these hand-worked schedules spell out the rules they follow.
+func TestHeapKeepsTieOrder(t *testing.T) {
+	timeline := func(resp SimulationResponse) string {
+		s := ""
+		for _, seg := range resp.Timeline {
+			s += fmt.Sprintf("%s[%d,%d) ", seg.ProcessID, seg.StartTime, seg.EndTime)
+		}
+		return s
+	}
+	procs := func(ps ...Process) []Process {
+		for i := range ps {
+			ps[i].RemainingTime = ps[i].BurstTime
+		}
+		return ps
+	}
+
+	// SRTF: at time 2 both have 3 left, and the running process keeps the
+	// CPU rather than switching to the tied arrival
+	srtf := runSRTF(procs(
+		Process{ID: "P2", ArrivalTime: 0, BurstTime: 5},
+		Process{ID: "P1", ArrivalTime: 2, BurstTime: 3},
+	), defaultTieBreaker)
+	if got, want := timeline(srtf), "P2[0,5) P1[5,8) "; got != want {
+		t.Errorf("SRTF tie: got %s, want %s", got, want)
+	}
+
+	// RR: simultaneous arrivals join the queue in tie-breaker order, not
+	// input order, so P1 runs before P2 under the default chain and P2
+	// first when only input order counts
+	rr := procs(
+		Process{ID: "P2", ArrivalTime: 0, BurstTime: 2},
+		Process{ID: "P1", ArrivalTime: 0, BurstTime: 2},
+	)
+	if got, want := timeline(runRoundRobin(rr, 1, "", false, defaultTieBreaker)), "P1[0,1) P2[1,2) P1[2,3) P2[3,4) "; got != want {
+		t.Errorf("RR simultaneous arrivals: got %s, want %s", got, want)
+	}
+	if got, want := timeline(runRoundRobin(rr, 1, "", false, tieBreaker{"input"})), "P2[0,1) P1[1,2) P2[2,3) P1[3,4) "; got != want {
+		t.Errorf("RR simultaneous arrivals by input: got %s, want %s", got, want)
+	}
+}
+
+func TestReadyHeapOrder(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	var entries []readyEntry
+	h := &readyHeap{}
+	for i := 0; i < 1000; i++ {
+		e := readyEntry{key: rng.IntN(20), rank: i, proc: i}
+		entries = append(entries, e)
+		h.push(e)
+
+		// Popping now and then mixes pushes and pops like a scheduler does
+		if i%7 == 6 {
+			top := h.pop()
+			for k, e := range entries {
+				if e == top {
+					entries = append(entries[:k], entries[k+1:]...)
+					break
+				}
+			}
+			for _, e := range entries {
+				if e.before(top) {
+					t.Fatalf("popped %v while %v was still queued", top, e)
+				}
+			}
+		}
+	}
+	sort.Slice(entries, func(a, b int) bool { return entries[a].before(entries[b]) })
+	for k, want := range entries {
+		if h.top() != want {
+			t.Fatalf("top %d is %v, want %v", k, h.top(), want)
+		}
+		if got := h.pop(); got != want {
+			t.Fatalf("pop %d is %v, want %v", k, got, want)
+		}
+	}
+	if h.Len() != 0 {
+		t.Fatalf("heap has %d entries left", h.Len())
+	}
+}
+
+func TestArrivalOrder(t *testing.T) {
+	procs := []Process{
+		{ID: "P3", ArrivalTime: 4},
+		{ID: "P10", ArrivalTime: 0},
+		{ID: "P2", ArrivalTime: 4},
+		{ID: "P1", ArrivalTime: 0},
+	}
+	cases := []struct {
+		tb   tieBreaker
+		want []int
+	}{
+		{defaultTieBreaker, []int{3, 1, 2, 0}},
+		{tieBreaker{"input"}, []int{1, 3, 0, 2}},
+	}
+	for _, c := range cases {
+		if got := arrivalOrder(procs, c.tb.ranks(procs)); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%v: got %v, want %v", c.tb, got, c.want)
+		}
+	}
+}
+
+// Tie-breaker ranks agree with comparing processes directly
+func TestTieBreakerRanks(t *testing.T) {
+	procs := benchmarkWorkload(500)
+	for _, tb := range []tieBreaker{defaultTieBreaker, {"id"}, {"input"}} {
+		rank := tb.ranks(procs)
+		for a := range procs {
+			for b := range procs {
+				if (rank[a] < rank[b]) != tb.before(procs, a, b) {
+					t.Fatalf("%v: ranks of %d and %d disagree with the tie-breaker", tb, a, b)
+				}
+			}
+		}
+	}
+}
+
+// Arrivals every 0-3 time units and bursts of 1-8, the same every run
+func benchmarkWorkload(n int) []Process {
+	rng := rand.New(rand.NewPCG(39, 39))
+	procs := make([]Process, n)
+	t := 0
+	for i := range procs {
+		t += rng.IntN(4)
+		b := 1 + rng.IntN(8)
+		procs[i] = Process{ID: "P" + strconv.Itoa(i+1), ArrivalTime: t, BurstTime: b, RemainingTime: b, Priority: rng.IntN(10)}
+	}
+	return procs
+}
+
+// Built on first use, so plain test runs do not pay for it
+var benchmarkProcesses = sync.OnceValue(func() []Process { return benchmarkWorkload(1000000) })
+
+func BenchmarkFCFS(b *testing.B) {
+	procs := benchmarkProcesses()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		runFCFS(procs, defaultTieBreaker)
+	}
+}
+
+func BenchmarkSJF(b *testing.B) {
+	procs := benchmarkProcesses()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		runSJF(procs, defaultTieBreaker)
+	}
+}
+
+func BenchmarkSRTF(b *testing.B) {
+	procs := benchmarkProcesses()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		runSRTF(procs, defaultTieBreaker)
+	}
+}
+
+func BenchmarkRR(b *testing.B) {
+	procs := benchmarkProcesses()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		runRoundRobin(procs, 2, "", false, defaultTieBreaker)
+	}
+}
+
+func BenchmarkPreemptivePriority(b *testing.B) {
+	procs := benchmarkProcesses()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		runPreemptivePriority(procs, false, defaultTieBreaker)
+	}
+}
+
+// The whole of POST /simulate on the same workload: decoding the JSON
+// body, validation, the run, metrics and encoding the response. The run*
+// benchmarks above are only the part in the middle.
+func benchmarkSimulate(b *testing.B, algorithm string, preemptive bool, quantum int) {
+	// The API counts priorities from 1, so shift the workload's up by one;
+	// the order they schedule in is the same.
+	procs := generatedProcesses(benchmarkProcesses())
+	for i := range procs {
+		procs[i].Priority++
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"algorithm":    algorithm,
+		"isPreemptive": preemptive,
+		"timeQuantum":  quantum,
+		"processes":    procs,
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	gin.SetMode(gin.ReleaseMode)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPost, "/simulate", bytes.NewReader(body))
+		handleSimulation(c)
+		if w.Code != http.StatusOK {
+			b.Fatalf("status %d: %.200s", w.Code, w.Body)
+		}
+	}
+}
+
+func BenchmarkSimulateFCFS(b *testing.B) { benchmarkSimulate(b, "FCFS", false, 0) }
+
+func BenchmarkSimulateSJF(b *testing.B) { benchmarkSimulate(b, "SJF", false, 0) }
+
+func BenchmarkSimulateSRTF(b *testing.B) { benchmarkSimulate(b, "SJF", true, 0) }
+
+func BenchmarkSimulateRR(b *testing.B) { benchmarkSimulate(b, "RR", false, 2) }
+
+func BenchmarkSimulatePreemptivePriority(b *testing.B) {
+	benchmarkSimulate(b, "Priority", true, 0)
+}
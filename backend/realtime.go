@@ -0,0 +1,571 @@
+package main
+
+import (
+	"sort"
+	"strconv"
+)
+
+// Real-time scheduling of periodic tasks. A process with a Period is a task
+// that releases a job of BurstTime every Period from its ArrivalTime until
+// the horizon, each due Deadline (default Period) after its release. A
+// process without a Period is an aperiodic job, served by the aperiodic
+// server if there is one and in the background otherwise.
+
+// ServerConfig describes the aperiodic server: Polling, Deferrable and
+// Sporadic servers run under fixed-priority (RM) scheduling, a Constant
+// Bandwidth Server (CBS) under EDF. The server gets Budget units of CPU per
+// Period.
+type ServerConfig struct {
+	Policy string `json:"policy"` // "Polling", "Deferrable", "Sporadic" or "CBS"
+	Budget int    `json:"budget" time:"ticks"`
+	Period int    `json:"period" time:"ticks"`
+}
+
+// BudgetPoint is the server's remaining budget from Time onwards. For a CBS
+// it also carries the server's current deadline.
+type BudgetPoint struct {
+	Time     int `json:"time" time:"ticks"`
+	Budget   int `json:"budget" time:"ticks"`
+	Deadline int `json:"deadline,omitempty" time:"ticks"`
+}
+
+type JobResponseTime struct {
+	ID           string `json:"id"`
+	ResponseTime int    `json:"responseTime" time:"ticks"`
+}
+
+// ServerReport shows how the aperiodic server behaved over the simulation
+type ServerReport struct {
+	Policy                   string            `json:"policy"`
+	Budget                   int               `json:"budget" time:"ticks"`
+	Period                   int               `json:"period" time:"ticks"`
+	BudgetTrace              []BudgetPoint     `json:"budgetTrace"`
+	AperiodicResponseTimes   []JobResponseTime `json:"aperiodicResponseTimes"`
+	AverageAperiodicResponse float64           `json:"averageAperiodicResponse" time:"average"`
+}
+
+// Longest horizon the simulator picks by itself, and the longest one a
+// request may ask for, in time units
+const (
+	maxHorizon          = 10000
+	maxRequestedHorizon = 1000000
+)
+
+// rtJob is one job in the real-time simulator
+type rtJob struct {
+	proc      Process
+	task      int // Index of the releasing task in the request, -1 for aperiodic jobs
+	input     int // Index in the request of the task or aperiodic job, for tie-breaking
+	priority  int // Fixed priority key, smaller runs first: period under RM, deadline under AMC
+	deadline  int // Absolute deadline
+	vdeadline int // Deadline EDF orders by; earlier than deadline for HI jobs under EDF-VD
+	remaining int
+}
+
+// rtServer holds the state of the aperiodic server
+type rtServer struct {
+	ServerConfig
+	budget   int
+	deadline int // CBS only
+
+	// Sporadic Server only: replenishments waiting to happen and the chunk
+	// of execution currently being accounted for
+	replenishments []BudgetPoint
+	chunkStart     int
+	chunkUsed      int
+}
+
+// Check the aperiodic server settings against the algorithm
+func validateServer(algorithm string, server *ServerConfig) []FieldError {
+	if server == nil {
+		return nil
+	}
+	var errs []FieldError
+	switch {
+	case server.Budget <= 0:
+		errs = append(errs, fieldError("server.budget", codeNotPositive, "Server budget must be positive and no larger than its period"))
+	case server.Period <= 0:
+		errs = append(errs, fieldError("server.period", codeNotPositive, "Server budget must be positive and no larger than its period"))
+	case server.Budget > server.Period:
+		errs = append(errs, fieldError("server.budget", codeOutOfRange, "Server budget must be positive and no larger than its period"))
+	}
+	switch server.Policy {
+	case "Polling", "Deferrable", "Sporadic":
+		if algorithm != "RM" {
+			errs = append(errs, fieldError("server.policy", codeUnsupported, "Polling, Deferrable and Sporadic servers need RM scheduling"))
+		}
+	case "CBS":
+		if algorithm != "EDF" {
+			errs = append(errs, fieldError("server.policy", codeUnsupported, "A Constant Bandwidth Server needs EDF scheduling"))
+		}
+	default:
+		errs = append(errs, fieldError("server.policy", codeUnknown, "Unknown server policy"))
+	}
+	return errs
+}
+
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+// Default horizon: one hyperperiod of the tasks and the server, at most
+// maxHorizon time units of scale ticks each
+func hyperperiod(processes []Process, server *ServerConfig, scale int64) int {
+	limit := maxHorizon * int(scale)
+	h := 0
+	add := func(period int) {
+		if h == 0 {
+			h = period
+			return
+		}
+		// The LCM only grows, so stop at the cap before it can overflow
+		m := h / gcd(h, period)
+		if m > limit/period {
+			h = limit
+			return
+		}
+		h = m * period
+	}
+	for _, p := range processes {
+		if p.Period > 0 {
+			add(p.Period)
+		}
+	}
+	if server != nil {
+		add(server.Period)
+	}
+	if h > limit {
+		h = limit
+	}
+	return h
+}
+
+// Rate Monotonic (RM) or Earliest Deadline First (EDF) scheduling of
+// periodic tasks, with aperiodic jobs served by an optional server. The
+// mixed-criticality algorithms AMC and EDF-VD are built on the same loop.
+func runRealtime(req SimulationRequest) SimulationResponse {
+	horizon := req.Horizon
+	if horizon <= 0 {
+		horizon = hyperperiod(req.Processes, req.Server, pow10(req.TimeResolution))
+	}
+	edf := req.Algorithm == "EDF" || req.Algorithm == "EDF-VD"
+	tb := requestTieBreaker(req)
+
+	// Mixed-criticality state: the system starts in LO mode and switches to
+	// HI mode the first time a HI job overruns its LO budget
+	mixed := req.Algorithm == "AMC" || req.Algorithm == "EDF-VD"
+	dropLO := req.LOCriticalityMode != "degrade"
+	hiMode := false
+	var mc *MixedCriticalityReport
+	virtualFactor := 1.0
+	if mixed {
+		mc = &MixedCriticalityReport{}
+		if req.Algorithm == "EDF-VD" {
+			virtualFactor = edfVDFactor(req.Processes)
+			mc.VirtualDeadlineFactor = virtualFactor
+		}
+	}
+
+	var jobs []*rtJob
+	var pending []*rtJob   // Released periodic jobs that have not finished
+	var aperiodic []*rtJob // Aperiodic jobs that have arrived, in arrival order
+	nextRelease := make([]int, len(req.Processes))
+	for i, p := range req.Processes {
+		nextRelease[i] = p.ArrivalTime
+	}
+
+	var srv *rtServer
+	var trace []BudgetPoint
+	if req.Server != nil {
+		srv = &rtServer{ServerConfig: *req.Server, budget: req.Server.Budget}
+	}
+	recordBudget := func(t int) {
+		if srv == nil {
+			return
+		}
+		point := BudgetPoint{Time: t, Budget: srv.budget}
+		if srv.Policy == "CBS" {
+			point.Deadline = srv.deadline
+		}
+		if n := len(trace); n > 0 && trace[n-1].Budget == point.Budget && trace[n-1].Deadline == point.Deadline {
+			return
+		}
+		if n := len(trace); n > 0 && trace[n-1].Time == t {
+			trace[n-1] = point
+			return
+		}
+		trace = append(trace, point)
+	}
+
+	var timeline []TimelineSegment
+	done := 0
+	total := 0
+	for _, p := range req.Processes {
+		if p.Period == 0 {
+			total++
+		}
+	}
+
+	for t := 0; ; t++ {
+		// Release periodic jobs due now
+		for i, p := range req.Processes {
+			if p.Period <= 0 {
+				continue
+			}
+			for nextRelease[i] <= t && nextRelease[i] < horizon {
+				n := (nextRelease[i] - p.ArrivalTime) / p.Period
+				deadline := p.Deadline
+				if deadline <= 0 {
+					deadline = p.Period
+				}
+				job := &rtJob{
+					proc:      p,
+					task:      i,
+					input:     i,
+					priority:  p.Period,
+					deadline:  nextRelease[i] + deadline,
+					vdeadline: nextRelease[i] + deadline,
+					remaining: p.BurstTime,
+				}
+				job.proc.ID = p.ID + "#" + strconv.Itoa(n)
+				job.proc.TaskID = p.ID
+				job.proc.ArrivalTime = nextRelease[i]
+				jobs = append(jobs, job)
+				total++
+				if nextRelease[i] >= horizon-p.Period {
+					nextRelease[i] = horizon // No more releases, and no overflow
+				} else {
+					nextRelease[i] += p.Period
+				}
+
+				if mixed {
+					if req.Algorithm == "AMC" {
+						job.priority = deadline // Deadline-monotonic
+					}
+					if isHICriticality(p) && !hiMode {
+						job.vdeadline = job.proc.ArrivalTime + virtualDeadline(deadline, virtualFactor)
+					}
+					// In HI mode, newly released LO jobs are dropped on arrival
+					if hiMode && dropLO && !isHICriticality(p) {
+						job.proc.Abandoned = true
+						mc.AbandonedJobs = append(mc.AbandonedJobs, job.proc.ID)
+						done++
+						continue
+					}
+				}
+
+				// A job with nothing to do is finished as soon as it is released
+				if job.remaining <= 0 {
+					job.proc.CompletionTime = job.proc.ArrivalTime
+					done++
+					continue
+				}
+				pending = append(pending, job)
+			}
+		}
+
+		// Aperiodic arrivals join the server's queue, in tie-breaker order
+		// when several arrive at once
+		var arrivals []int
+		for i, p := range req.Processes {
+			if p.Period == 0 && p.ArrivalTime == t {
+				arrivals = append(arrivals, i)
+			}
+		}
+		sort.SliceStable(arrivals, func(a, b int) bool {
+			return tb.before(req.Processes, arrivals[a], arrivals[b])
+		})
+		for _, i := range arrivals {
+			p := req.Processes[i]
+			job := &rtJob{proc: p, task: -1, input: i, remaining: p.BurstTime}
+			jobs = append(jobs, job)
+			if job.remaining <= 0 {
+				job.proc.CompletionTime = t
+				done++
+				continue
+			}
+
+			// A CBS that wakes up keeps its deadline only if its remaining
+			// budget would not exceed its bandwidth until that deadline
+			if srv != nil && srv.Policy == "CBS" && len(aperiodic) == 0 {
+				if srv.deadline <= t || srv.budget*srv.Period >= (srv.deadline-t)*srv.Budget {
+					srv.deadline = t + srv.Period
+					srv.budget = srv.Budget
+				}
+			}
+			aperiodic = append(aperiodic, job)
+		}
+
+		// Server replenishment
+		if srv != nil {
+			switch srv.Policy {
+			case "Polling":
+				// Full budget at each period, but it is lost straight away if
+				// no aperiodic work is waiting
+				if t%srv.Period == 0 {
+					srv.budget = srv.Budget
+				}
+				if len(aperiodic) == 0 {
+					srv.budget = 0
+				}
+			case "Deferrable":
+				// Full budget at each period, kept until used
+				if t%srv.Period == 0 {
+					srv.budget = srv.Budget
+				}
+			case "Sporadic":
+				// Budget comes back one period after it was consumed
+				var later []BudgetPoint
+				for _, r := range srv.replenishments {
+					if r.Time <= t {
+						srv.budget += r.Budget
+					} else {
+						later = append(later, r)
+					}
+				}
+				srv.replenishments = later
+			}
+			recordBudget(t)
+		}
+
+		if done == total && t >= horizon {
+			break
+		}
+
+		// Pick the highest priority ready entity. The server competes with
+		// its period under RM and its deadline under EDF; without a server,
+		// aperiodic jobs only run when no periodic job is ready.
+		var run *rtJob
+		serverRuns := false
+		for _, job := range pending {
+			// Degraded LO jobs only get the CPU when no HI job is ready
+			if hiMode && run != nil && isHICriticality(run.proc) != isHICriticality(job.proc) {
+				if isHICriticality(job.proc) {
+					run = job
+				}
+				continue
+			}
+			if run == nil || rtBefore(job, run, edf, tb) {
+				run = job
+			}
+		}
+		if srv != nil && len(aperiodic) > 0 && (srv.budget > 0 || srv.Policy == "CBS") {
+			key := &rtJob{priority: srv.Period, vdeadline: srv.deadline}
+			if run == nil || rtCompare(run, key, edf) >= 0 {
+				run = aperiodic[0]
+				serverRuns = true
+			}
+		}
+		if run == nil && srv == nil && len(aperiodic) > 0 {
+			run = aperiodic[0]
+		}
+
+		// Sporadic Server: a chunk of execution ends when the server stops
+		// running, and its budget is replenished one period after it began
+		if srv != nil && srv.Policy == "Sporadic" && !serverRuns && srv.chunkUsed > 0 {
+			srv.replenishments = append(srv.replenishments, BudgetPoint{Time: srv.chunkStart + srv.Period, Budget: srv.chunkUsed})
+			srv.chunkUsed = 0
+		}
+
+		// CPU idle: nothing changes until the next release, arrival or
+		// replenishment, so skip straight to it. Aperiodic jobs held back
+		// by the server's budget wait all the while.
+		if run == nil {
+			next := max(rtNextEvent(req.Processes, nextRelease, horizon, srv, len(aperiodic) > 0, t), t+1)
+			for _, job := range aperiodic {
+				job.proc.WaitingTime += next - t
+			}
+			t = next - 1
+			continue
+		}
+
+		// Everyone else that is ready waits for this unit
+		for _, job := range pending {
+			if job != run {
+				job.proc.WaitingTime++
+			}
+		}
+		for _, job := range aperiodic {
+			if job != run {
+				job.proc.WaitingTime++
+			}
+		}
+
+		if !run.proc.IsStarted {
+			run.proc.IsStarted = true
+			run.proc.StartTime = t
+			run.proc.ResponseTime = t - run.proc.ArrivalTime
+		}
+		if n := len(timeline); n > 0 && timeline[n-1].ProcessID == run.proc.ID && timeline[n-1].EndTime == t {
+			timeline[n-1].EndTime++
+		} else {
+			timeline = append(timeline, TimelineSegment{ProcessID: run.proc.ID, StartTime: t, EndTime: t + 1})
+		}
+		run.remaining--
+
+		if serverRuns {
+			if srv.Policy == "Sporadic" && srv.chunkUsed == 0 {
+				srv.chunkStart = t
+			}
+			srv.chunkUsed++
+			srv.budget--
+		}
+
+		// A HI job still running after its LO budget triggers the mode switch
+		executed := run.proc.BurstTime - run.remaining
+		if mixed && !hiMode && isHICriticality(run.proc) && run.remaining > 0 && executed >= loWCET(run.proc) {
+			hiMode = true
+			switchTime := t + 1
+			mc.ModeSwitchTime = &switchTime
+			timeline = append(timeline, TimelineSegment{StartTime: switchTime, EndTime: switchTime, Kind: "mode-switch"})
+
+			var kept []*rtJob
+			for _, job := range pending {
+				switch {
+				case isHICriticality(job.proc):
+					job.vdeadline = job.deadline // Back to real deadlines
+					kept = append(kept, job)
+				case dropLO:
+					job.proc.Abandoned = true
+					mc.AbandonedJobs = append(mc.AbandonedJobs, job.proc.ID)
+					done++
+				default:
+					kept = append(kept, job)
+				}
+			}
+			pending = kept
+		}
+
+		if run.remaining == 0 {
+			run.proc.CompletionTime = t + 1
+			run.proc.TurnaroundTime = run.proc.CompletionTime - run.proc.ArrivalTime
+			if run.task != -1 {
+				run.proc.DeadlineMissed = run.proc.CompletionTime > run.deadline
+				pending = removeJob(pending, run)
+			} else {
+				aperiodic = aperiodic[1:]
+			}
+			done++
+		}
+
+		if srv != nil {
+			switch {
+			case srv.Policy == "Polling" && len(aperiodic) == 0:
+				// Nothing left to serve: the rest of the budget is lost
+				srv.budget = 0
+			case srv.Policy == "CBS" && srv.budget == 0:
+				// Budget exhausted: recharge and postpone the deadline
+				srv.budget = srv.Budget
+				srv.deadline += srv.Period
+			}
+			recordBudget(t + 1)
+		}
+	}
+
+	// Jobs come out in release order
+	procs := make([]Process, len(jobs))
+	for i, job := range jobs {
+		procs[i] = job.proc
+	}
+
+	response := SimulationResponse{
+		Processes: procs,
+		Timeline:  timeline,
+	}
+	if srv != nil {
+		report := &ServerReport{
+			Policy:      srv.Policy,
+			Budget:      srv.Budget,
+			Period:      srv.Period,
+			BudgetTrace: trace,
+		}
+		var sum int
+		for _, job := range jobs {
+			if job.task == -1 {
+				report.AperiodicResponseTimes = append(report.AperiodicResponseTimes, JobResponseTime{
+					ID:           job.proc.ID,
+					ResponseTime: job.proc.TurnaroundTime,
+				})
+				sum += job.proc.TurnaroundTime
+			}
+		}
+		if n := len(report.AperiodicResponseTimes); n > 0 {
+			report.AverageAperiodicResponse = float64(sum) / float64(n)
+		}
+		response.Server = report
+	}
+	response.MixedCriticality = mc
+	return response
+}
+
+// Earliest time after t at which an idle CPU could get work: a periodic
+// release before the horizon, an aperiodic arrival, a server replenishment
+// that matters, or the horizon itself. t+1 if there is none.
+func rtNextEvent(processes []Process, nextRelease []int, horizon int, srv *rtServer, waiting bool, t int) int {
+	next := -1
+	consider := func(at int) {
+		if at > t && (next == -1 || at < next) {
+			next = at
+		}
+	}
+	for i, p := range processes {
+		switch {
+		case p.Period > 0 && nextRelease[i] < horizon:
+			consider(nextRelease[i])
+		case p.Period == 0:
+			consider(p.ArrivalTime)
+		}
+	}
+	consider(horizon)
+	if srv != nil {
+		boundary := (t/srv.Period + 1) * srv.Period
+		switch {
+		case srv.Policy == "Polling" && waiting:
+			consider(boundary)
+		case srv.Policy == "Deferrable" && srv.budget < srv.Budget:
+			consider(boundary)
+		case srv.Policy == "Sporadic":
+			for _, r := range srv.replenishments {
+				consider(r.Time)
+			}
+		}
+	}
+	if next == -1 {
+		return t + 1
+	}
+	return next
+}
+
+// Compare jobs a and b by scheduling key alone: earlier (virtual) deadline
+// under EDF, smaller priority key under fixed priority. Negative if a has
+// priority.
+func rtCompare(a, b *rtJob, edf bool) int {
+	if edf {
+		return a.vdeadline - b.vdeadline
+	}
+	return a.priority - b.priority
+}
+
+// Whether job a has priority over job b, with ties going to the
+// tie-breaker; an earlier release always beats a later job of the same task
+func rtBefore(a, b *rtJob, edf bool, tb tieBreaker) bool {
+	if c := rtCompare(a, b, edf); c != 0 {
+		return c < 0
+	}
+	if a.task != -1 && a.task == b.task {
+		return a.proc.ArrivalTime < b.proc.ArrivalTime
+	}
+	return tb.compare(a.proc, a.input, b.proc, b.input) < 0
+}
+
+func removeJob(jobs []*rtJob, job *rtJob) []*rtJob {
+	for i, j := range jobs {
+		if j == job {
+			return append(jobs[:i], jobs[i+1:]...)
+		}
+	}
+	return jobs
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+// An aperiodic job waiting for server budget while the CPU idles is still
+// waiting, so its waiting time is its turnaround less its burst
+func TestAperiodicWaitsForBudget(t *testing.T) {
+	cases := []struct {
+		algorithm, policy string
+		turnaround        int // Of the aperiodic job
+	}{
+		{"RM", "Polling", 15},
+		{"RM", "Deferrable", 10},
+		{"RM", "Sporadic", 11},
+		{"EDF", "CBS", 4},
+	}
+	for _, tc := range cases {
+		req := SimulationRequest{
+			Algorithm: tc.algorithm,
+			Horizon:   20,
+			Processes: []Process{
+				{ID: "T1", BurstTime: 2, Period: 10},
+				{ID: "A", ArrivalTime: 1, BurstTime: 3},
+			},
+			Server: &ServerConfig{Policy: tc.policy, Budget: 1, Period: 5},
+		}
+		if errs := validateRequest(req, nil); len(errs) > 0 {
+			t.Fatalf("%s: %v", tc.policy, errs)
+		}
+		result, _ := simulate(req, 1)
+		for _, p := range result.Processes {
+			if p.WaitingTime != p.TurnaroundTime-p.BurstTime {
+				t.Errorf("%s: %s waited %d with turnaround %d and burst %d",
+					tc.policy, p.ID, p.WaitingTime, p.TurnaroundTime, p.BurstTime)
+			}
+			if p.ID == "A" && p.TurnaroundTime != tc.turnaround {
+				t.Errorf("%s: A's turnaround is %d, want %d", tc.policy, p.TurnaroundTime, tc.turnaround)
+			}
+		}
+	}
+}
+
+// Polling loses its budget when nothing is waiting, Deferrable keeps it,
+// Sporadic gets back what it used one period after using it, and a CBS
+// postpones its deadline when its budget runs out
+func TestServerSchedules(t *testing.T) {
+	budgets := func(want string) func(t *testing.T, r SimulationResponse) {
+		return func(t *testing.T, r SimulationResponse) {
+			got := ""
+			for i, p := range r.Server.BudgetTrace {
+				if i > 0 {
+					got += ", "
+				}
+				got += fmt.Sprintf("%d@%d", p.Budget, p.Time)
+				if p.Deadline > 0 {
+					got += fmt.Sprintf("/d%d", p.Deadline)
+				}
+			}
+			if got != want {
+				t.Errorf("budget trace:\n got %s\nwant %s", got, want)
+			}
+		}
+	}
+	runScheduleCases(t, []scheduleCase{
+		{
+			name:     "polling",
+			body:     `{"algorithm": "RM", "horizon": 10, "server": {"policy": "Polling", "budget": 2, "period": 5}, "processes": [{"id": "T1", "burstTime": 4, "period": 10}, {"id": "A", "arrivalTime": 1, "burstTime": 2}]}`,
+			timeline: "T1#0 0-4, A 5-7",
+			times:    "T1#0 0/4, A 4/6",
+			check:    budgets("0@0, 2@5, 1@6, 0@7"),
+		},
+		{
+			name:     "deferrable",
+			body:     `{"algorithm": "RM", "horizon": 10, "server": {"policy": "Deferrable", "budget": 2, "period": 5}, "processes": [{"id": "T1", "burstTime": 4, "period": 10}, {"id": "A", "arrivalTime": 1, "burstTime": 2}]}`,
+			timeline: "T1#0 0-1, A 1-3, T1#0 3-6",
+			times:    "T1#0 2/6, A 0/2",
+			check:    budgets("2@0, 1@2, 0@3, 2@5"),
+		},
+		{
+			name:     "sporadic",
+			body:     `{"algorithm": "RM", "horizon": 10, "server": {"policy": "Sporadic", "budget": 2, "period": 5}, "processes": [{"id": "T1", "burstTime": 4, "period": 10}, {"id": "A", "arrivalTime": 1, "burstTime": 1}, {"id": "B", "arrivalTime": 3, "burstTime": 2}]}`,
+			timeline: "T1#0 0-1, A 1-2, T1#0 2-3, B 3-4, T1#0 4-6, B 6-7",
+			times:    "T1#0 2/6, A 0/1, B 2/4",
+			check:    budgets("2@0, 1@2, 0@4, 1@6, 0@7, 1@8"),
+		},
+		{
+			name:     "cbs",
+			body:     `{"algorithm": "EDF", "horizon": 10, "server": {"policy": "CBS", "budget": 2, "period": 5}, "processes": [{"id": "T1", "burstTime": 4, "period": 10}, {"id": "A", "arrivalTime": 1, "burstTime": 3}]}`,
+			timeline: "T1#0 0-1, A 1-3, T1#0 3-6, A 6-7",
+			times:    "T1#0 2/6, A 3/6",
+			check:    budgets("2@0, 2@1/d6, 1@2/d6, 2@3/d11, 1@7/d11"),
+		},
+	})
+}
+
+// At 97% utilization RM misses a deadline that EDF meets
+func TestRMAndEDF(t *testing.T) {
+	missed := func(want string) func(t *testing.T, r SimulationResponse) {
+		return func(t *testing.T, r SimulationResponse) {
+			got := ""
+			for _, p := range r.Processes {
+				if p.DeadlineMissed {
+					got += p.ID
+				}
+			}
+			if got != want {
+				t.Errorf("missed deadlines: got %q, want %q", got, want)
+			}
+		}
+	}
+	tasks := `[{"id": "T1", "burstTime": 2, "period": 5}, {"id": "T2", "burstTime": 4, "period": 7}]`
+	runScheduleCases(t, []scheduleCase{
+		{
+			name:     "rm",
+			body:     `{"algorithm": "RM", "horizon": 14, "processes": ` + tasks + `}`,
+			timeline: "T1#0 0-2, T2#0 2-5, T1#1 5-7, T2#0 7-8, T2#1 8-10, T1#2 10-12, T2#1 12-14",
+			times:    "T1#0 0/2, T2#0 4/8, T1#1 0/2, T2#1 3/7, T1#2 0/2",
+			check:    missed("T2#0"),
+		},
+		{
+			name:     "edf",
+			body:     `{"algorithm": "EDF", "horizon": 14, "processes": ` + tasks + `}`,
+			timeline: "T1#0 0-2, T2#0 2-6, T1#1 6-8, T2#1 8-12, T1#2 12-14",
+			times:    "T1#0 0/2, T2#0 2/6, T1#1 1/3, T2#1 1/5, T1#2 2/4",
+			check:    missed(""),
+		},
+	})
+}
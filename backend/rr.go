@@ -0,0 +1,249 @@
+package main
+
+import "sort"
+
+// Round Robin conventions and variants. Plain RR and dynamic-quantum RR
+// live in runRoundRobin, Virtual Round Robin needs I/O so it lives in the
+// step engine, and Selfish RR is here.
+//
+// Textbooks disagree on what happens when a process is preempted at the
+// same instant another one arrives. RRQueueOrder picks the convention:
+// "arrivals-first" (default) queues the newcomer first, "preempted-first"
+// puts the preempted process ahead of it.
+
+// SelfishRRConfig sets how fast priorities grow under Selfish RR: new
+// processes wait outside the RR queue, gaining NewRate priority per time
+// unit, until they catch up with the accepted processes, which gain
+// AcceptedRate per time unit. With AcceptedRate 0 it is plain RR; with
+// AcceptedRate >= NewRate it degenerates into FCFS.
+type SelfishRRConfig struct {
+	NewRate      int `json:"newRate"`
+	AcceptedRate int `json:"acceptedRate"`
+}
+
+// Rates used when the request does not set them
+var defaultSelfishRR = SelfishRRConfig{NewRate: 2, AcceptedRate: 1}
+
+// Check the Round Robin options of a request
+func validateRR(req SimulationRequest) []FieldError {
+	var errs []FieldError
+	switch req.RRQueueOrder {
+	case "", "arrivals-first", "preempted-first":
+	default:
+		errs = append(errs, fieldError("rrQueueOrder", codeUnknown, "Unknown RR queue order"))
+	}
+	switch req.QuantumRule {
+	case "", "median", "mean":
+	default:
+		errs = append(errs, fieldError("quantumRule", codeUnknown, "Unknown quantum rule"))
+	}
+	if req.SelfishRR != nil {
+		if req.SelfishRR.NewRate < 0 {
+			errs = append(errs, fieldError("selfishRR.newRate", codeNegative, "Selfish RR rates cannot be negative"))
+		}
+		if req.SelfishRR.AcceptedRate < 0 {
+			errs = append(errs, fieldError("selfishRR.acceptedRate", codeNegative, "Selfish RR rates cannot be negative"))
+		}
+	}
+	return errs
+}
+
+// Quantum for dynamic-quantum RR: the median or the mean of the ready
+// processes' remaining bursts, rounded up and at least 1
+func dynamicQuantum(remaining []int, rule string) int {
+	sorted := make([]int, len(remaining))
+	copy(sorted, remaining)
+	sort.Ints(sorted)
+
+	q := 1
+	n := len(sorted)
+	switch {
+	case n == 0:
+	case rule == "mean":
+		sum := 0
+		for _, r := range sorted {
+			sum += r
+		}
+		q = (sum + n - 1) / n
+	case n%2 == 1:
+		q = sorted[n/2]
+	default:
+		q = (sorted[n/2-1] + sorted[n/2] + 1) / 2
+	}
+	if q < 1 {
+		q = 1
+	}
+	return q
+}
+
+// Selfish Round Robin. Arrivals wait in a holding queue until their
+// priority reaches the lowest priority among accepted processes (or
+// straight away when nobody is accepted), then join the RR queue.
+func runSelfishRR(processes []Process, timeQuantum int, cfg *SelfishRRConfig, preemptedFirst bool, tb tieBreaker) SimulationResponse {
+	if timeQuantum <= 0 {
+		timeQuantum = 1 // Default time quantum, same as runRoundRobin
+	}
+	rates := defaultSelfishRR
+	if cfg != nil {
+		rates = *cfg
+	}
+
+	// Make a copy of processes
+	procs := make([]Process, len(processes))
+	copy(procs, processes)
+
+	arrived := make([]bool, len(procs))
+	accepted := make([]bool, len(procs))
+	priority := make([]int, len(procs))
+	var holding []int // Arrived but not yet accepted
+	var queue []int   // Accepted and ready, in RR order
+	running := -1
+	sliceUsed := 0
+	completed := 0
+	var timeline []TimelineSegment
+
+	// Start the clock at the earliest arrival
+	currentTime := procs[0].ArrivalTime
+	for _, p := range procs {
+		if p.ArrivalTime < currentTime {
+			currentTime = p.ArrivalTime
+		}
+	}
+
+	finish := func(i int) {
+		procs[i].CompletionTime = currentTime
+		procs[i].TurnaroundTime = procs[i].CompletionTime - procs[i].ArrivalTime
+		procs[i].WaitingTime = procs[i].TurnaroundTime - procs[i].BurstTime
+		completed++
+	}
+
+	for completed < len(procs) {
+		// Arrivals join the holding queue
+		var newcomers []int
+		for i, p := range procs {
+			if !arrived[i] && p.ArrivalTime <= currentTime {
+				arrived[i] = true
+				if p.BurstTime <= 0 {
+					procs[i].StartTime = currentTime
+					finish(i)
+					continue
+				}
+				newcomers = append(newcomers, i)
+			}
+		}
+		sort.SliceStable(newcomers, func(a, b int) bool {
+			return tb.before(procs, newcomers[a], newcomers[b])
+		})
+		holding = append(holding, newcomers...)
+
+		// Accept every held process that has caught up with the accepted
+		// ones; with nobody accepted, the longest-waiting ones go in
+		threshold := -1
+		for i := range procs {
+			if accepted[i] && procs[i].RemainingTime > 0 && (threshold == -1 || priority[i] < threshold) {
+				threshold = priority[i]
+			}
+		}
+		if threshold == -1 {
+			for _, i := range holding {
+				if priority[i] > threshold {
+					threshold = priority[i]
+				}
+			}
+		}
+		var admitted, still []int
+		for _, i := range holding {
+			if priority[i] >= threshold {
+				accepted[i] = true
+				admitted = append(admitted, i)
+			} else {
+				still = append(still, i)
+			}
+		}
+		holding = still
+		sort.SliceStable(admitted, func(a, b int) bool {
+			if priority[admitted[a]] != priority[admitted[b]] {
+				return priority[admitted[a]] > priority[admitted[b]]
+			}
+			return tb.before(procs, admitted[a], admitted[b])
+		})
+
+		// A process whose quantum is up goes to the back of the queue,
+		// before or after the processes accepted at this instant
+		if running != -1 && sliceUsed >= timeQuantum && len(queue)+len(admitted) > 0 {
+			if preemptedFirst {
+				queue = append(queue, running)
+				queue = append(queue, admitted...)
+			} else {
+				queue = append(queue, admitted...)
+				queue = append(queue, running)
+			}
+			running = -1
+		} else {
+			queue = append(queue, admitted...)
+			if running != -1 && sliceUsed >= timeQuantum {
+				sliceUsed = 0 // Nobody else can run, start a fresh quantum
+			}
+		}
+
+		if running == -1 && len(queue) > 0 {
+			running = queue[0]
+			queue = queue[1:]
+			sliceUsed = 0
+			if !procs[running].IsStarted {
+				procs[running].StartTime = currentTime
+				procs[running].ResponseTime = currentTime - procs[running].ArrivalTime
+				procs[running].IsStarted = true
+			}
+		}
+
+		// Nothing to run: jump ahead to the next arrival
+		if running == -1 {
+			next := -1
+			for i, p := range procs {
+				if !arrived[i] && (next == -1 || p.ArrivalTime < next) {
+					next = p.ArrivalTime
+				}
+			}
+			if next == -1 {
+				break
+			}
+			currentTime = next
+			continue
+		}
+
+		// Run one time unit, extending the running process's segment
+		if n := len(timeline); n > 0 && timeline[n-1].ProcessID == procs[running].ID && timeline[n-1].EndTime == currentTime {
+			timeline[n-1].EndTime++
+		} else {
+			timeline = append(timeline, TimelineSegment{
+				ProcessID: procs[running].ID,
+				StartTime: currentTime,
+				EndTime:   currentTime + 1,
+			})
+		}
+
+		// Priorities grow: fast while held, slowly once accepted
+		for _, i := range holding {
+			priority[i] += rates.NewRate
+		}
+		for i := range procs {
+			if accepted[i] && procs[i].RemainingTime > 0 {
+				priority[i] += rates.AcceptedRate
+			}
+		}
+
+		currentTime++
+		procs[running].RemainingTime--
+		sliceUsed++
+		if procs[running].RemainingTime == 0 {
+			finish(running)
+			running = -1
+		}
+	}
+
+	return SimulationResponse{
+		Processes: procs,
+		Timeline:  timeline,
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import "testing"
+
+func TestRoundRobinSchedules(t *testing.T) {
+	// A runs for 1 tick of its 4-tick quantum, then blocks on I/O until 3
+	ioBound := `"timeQuantum": 4, "processes": [{"id": "A", "burstTime": 3, "ioBursts": [{"offset": 1, "duration": 2}]}, {"id": "B", "burstTime": 10}, {"id": "C", "burstTime": 10}]}`
+	runScheduleCases(t, []scheduleCase{
+		{
+			// Back from I/O, A goes to the auxiliary queue and runs ahead of
+			// C, which has been waiting since 0
+			name:     "vrr auxiliary queue",
+			body:     `{"algorithm": "VRR", ` + ioBound,
+			timeline: "A 0-1, B 1-5, A 5-7, C 7-11, B 11-15, C 15-19, B 19-21, C 21-23",
+			times:    "A 2/7, B 11/21, C 13/23",
+		},
+		{
+			name:     "rr after io",
+			body:     `{"algorithm": "RR", ` + ioBound,
+			timeline: "A 0-1, B 1-5, C 5-9, A 9-11, B 11-15, C 15-19, B 19-21, C 21-23",
+			times:    "A 6/11, B 11/21, C 13/23",
+		},
+		{
+			// P2 arrives as P1's quantum ends
+			name:     "arrivals first",
+			body:     `{"algorithm": "RR", "timeQuantum": 2, "processes": [{"id": "P1", "burstTime": 4}, {"id": "P2", "arrivalTime": 2, "burstTime": 2}]}`,
+			timeline: "P1 0-2, P2 2-4, P1 4-6",
+			times:    "P1 2/6, P2 0/2",
+		},
+		{
+			name:     "preempted first",
+			body:     `{"algorithm": "RR", "timeQuantum": 2, "rrQueueOrder": "preempted-first", "processes": [{"id": "P1", "burstTime": 4}, {"id": "P2", "arrivalTime": 2, "burstTime": 2}]}`,
+			timeline: "P1 0-2, P1 2-4, P2 4-6",
+			times:    "P1 0/4, P2 2/4",
+		},
+		{
+			// P2 gains priority twice as fast as P1 and catches up at 2
+			name:     "selfish",
+			body:     `{"algorithm": "SelfishRR", "timeQuantum": 1, "selfishRR": {"newRate": 2, "acceptedRate": 1}, "processes": [{"id": "P1", "burstTime": 4}, {"id": "P2", "arrivalTime": 1, "burstTime": 3}]}`,
+			timeline: "P1 0-2, P2 2-3, P1 3-4, P2 4-5, P1 5-6, P2 6-7",
+			times:    "P1 2/6, P2 3/6",
+		},
+		{
+			// The quantum is the median remaining burst, 6 and then 9
+			name:     "dynamic quantum",
+			body:     `{"algorithm": "DynamicRR", "processes": [{"id": "P1", "burstTime": 2}, {"id": "P2", "burstTime": 6}, {"id": "P3", "burstTime": 9}]}`,
+			timeline: "P1 0-2, P2 2-8, P3 8-17",
+			times:    "P1 0/2, P2 2/8, P3 8/17",
+		},
+	})
+}
@@ -0,0 +1,828 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Simulation sessions: a finished run replayed one event at a time. Every
+// simulator produces a timeline rather than a decision log, so the steps
+// are rebuilt from the timeline and the processes, the same way the queue
+// statistics are, and each scheduling decision is explained from the
+// algorithm's rule. Linux's vruntimes cannot be rebuilt that way, so the
+// simulator records where it placed them.
+
+// Limits on what sessions hold in memory: sessions, entities, ticks of
+// work to simulate, and entity states over all the steps of one session
+const (
+	maxSessions        = 1000
+	maxSessionEntities = 2000
+	maxSessionTicks    = 1000000
+	maxSessionStates   = 2000000
+	sessionIdleTimeout = 30 * time.Minute
+)
+
+// SessionStep is the system state from Time until the next step
+type SessionStep struct {
+	Time int `json:"time" time:"ticks"`
+
+	// Entities on the CPU (several with more than one CPU), waiting in the
+	// ready queue in the order they joined it, and blocked
+	Running []string `json:"running"`
+	Ready   []string `json:"ready"`
+	Blocked []string `json:"blocked,omitempty"`
+
+	// Work left for everything that has arrived and not finished
+	Remaining []RemainingWork `json:"remaining"`
+
+	// What happened at this instant, such as "P2 arrives", and why the
+	// CPU went to whoever is running
+	Events []string `json:"events,omitempty"`
+	Reason string   `json:"reason"`
+}
+
+type RemainingWork struct {
+	ID        string `json:"id"`
+	Remaining int    `json:"remaining" time:"ticks"`
+}
+
+type SessionResponse struct {
+	ID        string      `json:"id"`
+	Index     int         `json:"index"`
+	StepCount int         `json:"stepCount"`
+	Done      bool        `json:"done"` // At the last step
+	Step      SessionStep `json:"step"`
+
+	// The whole run, sent when the session is created
+	Result *SimulationResponse `json:"result,omitempty"`
+}
+
+type session struct {
+	steps      []SessionStep
+	cursor     int
+	scale      int64
+	resolution int
+	lastUsed   time.Time
+}
+
+// Live sessions by ID
+var sessions = struct {
+	sync.Mutex
+	byID map[string]*session
+}{byID: make(map[string]*session)}
+
+func handleCreateSession(c *gin.Context) {
+	var req SimulationRequest
+	scale, raw, ok := decodeBody(c, &req)
+	if !ok {
+		return
+	}
+	if errs := validateRequest(req, raw); len(errs) > 0 {
+		rejectRequest(c, errs)
+		return
+	}
+	if errs := validateSession(req); len(errs) > 0 {
+		rejectRequest(c, errs)
+		return
+	}
+
+	result, ok := simulate(req, scale)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown algorithm"})
+		return
+	}
+	steps, ok := sessionSteps(req, result, scale)
+	if !ok {
+		rejectRequest(c, []FieldError{fieldError("processes", codeOutOfRange,
+			fmt.Sprintf("A session can hold at most %d process states over all its steps; step through fewer processes or a shorter run", maxSessionStates))})
+		return
+	}
+	s := &session{
+		steps:      steps,
+		scale:      scale,
+		resolution: req.TimeResolution,
+		lastUsed:   time.Now(),
+	}
+	id := newSessionID()
+	sessions.Lock()
+	storeSession(id, s)
+	response := s.response(id)
+	sessions.Unlock()
+
+	response.Result = &result
+	respond(c, response, req.TimeResolution)
+}
+
+// Check that a validated request is small enough to step through
+func validateSession(req SimulationRequest) []FieldError {
+	if n := len(schedEntities(req.Processes)); n > maxSessionEntities {
+		return []FieldError{fieldError("processes", codeOutOfRange,
+			fmt.Sprintf("A session can step through at most %d processes and threads", maxSessionEntities))}
+	}
+	ticks := steppedTicks(req)
+	if ticks == 0 {
+		ticks = totalWork(req.Processes)
+	}
+	if ticks > maxSessionTicks {
+		return []FieldError{fieldError("processes", codeOutOfRange,
+			fmt.Sprintf("A session can step through at most %d ticks of work; use a coarser time resolution or less work", maxSessionTicks))}
+	}
+	return nil
+}
+
+func handleGetSession(c *gin.Context) {
+	withSession(c, func(id string, s *session) {
+		respond(c, s.response(id), s.resolution)
+	})
+}
+
+func handleDeleteSession(c *gin.Context) {
+	sessions.Lock()
+	defer sessions.Unlock()
+	if _, ok := sessions.byID[c.Param("id")]; !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+	delete(sessions.byID, c.Param("id"))
+	c.Status(http.StatusNoContent)
+}
+
+func handleSessionStep(c *gin.Context) {
+	withSession(c, func(id string, s *session) {
+		if s.cursor == len(s.steps)-1 {
+			c.JSON(http.StatusConflict, gin.H{"error": "The simulation has finished"})
+			return
+		}
+		s.cursor++
+		respond(c, s.response(id), s.resolution)
+	})
+}
+
+func handleSessionBack(c *gin.Context) {
+	withSession(c, func(id string, s *session) {
+		if s.cursor == 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Already at the first step"})
+			return
+		}
+		s.cursor--
+		respond(c, s.response(id), s.resolution)
+	})
+}
+
+// Advance to the last step at or before time t, given in the time unit
+func handleSessionRunUntil(c *gin.Context) {
+	withSession(c, func(id string, s *session) {
+		t, err := scaleNumber(json.Number(c.Query("t")), s.scale, "t", "t")
+		if err != nil {
+			if c.Query("t") == "" {
+				err.Code, err.Message = codeRequired, "t is required"
+			}
+			rejectRequest(c, []FieldError{*err})
+			return
+		}
+		ticks, _ := t.Int64()
+		if int(ticks) < s.steps[s.cursor].Time {
+			rejectRequest(c, []FieldError{fieldError("t", codeOutOfRange, "t is before the current step; use back to rewind")})
+			return
+		}
+		for s.cursor+1 < len(s.steps) && s.steps[s.cursor+1].Time <= int(ticks) {
+			s.cursor++
+		}
+		respond(c, s.response(id), s.resolution)
+	})
+}
+
+// Run f on the session named in the path with the sessions locked, or
+// answer 404
+func withSession(c *gin.Context, f func(id string, s *session)) {
+	sessions.Lock()
+	defer sessions.Unlock()
+	id := c.Param("id")
+	s, ok := sessions.byID[id]
+	if !ok || time.Since(s.lastUsed) > sessionIdleTimeout {
+		delete(sessions.byID, id)
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+	s.lastUsed = time.Now()
+	f(id, s)
+}
+
+// Keep a new session, dropping idle ones and, when full, the least recently
+// used. The caller holds the lock.
+func storeSession(id string, s *session) {
+	oldest := ""
+	for key, other := range sessions.byID {
+		if time.Since(other.lastUsed) > sessionIdleTimeout {
+			delete(sessions.byID, key)
+			continue
+		}
+		if oldest == "" || other.lastUsed.Before(sessions.byID[oldest].lastUsed) {
+			oldest = key
+		}
+	}
+	if len(sessions.byID) >= maxSessions {
+		delete(sessions.byID, oldest)
+	}
+	sessions.byID[id] = s
+}
+
+func newSessionID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func (s *session) response(id string) SessionResponse {
+	return SessionResponse{
+		ID:        id,
+		Index:     s.cursor,
+		StepCount: len(s.steps),
+		Done:      s.cursor == len(s.steps)-1,
+		Step:      s.steps[s.cursor],
+	}
+}
+
+// sessionEntity is a schedulable entity with everything a step needs. Its
+// segments and intervals are in time order and do not overlap, so each
+// lookup is a binary search.
+type sessionEntity struct {
+	schedEntity
+	segments []TimelineSegment
+	done     []int // Work in segments[:k+1], for each k
+	total    int   // Work over the whole run
+	ready    []interval
+	blocked  []interval
+}
+
+// Add the next segment the entity ran in
+func (e *sessionEntity) add(seg TimelineSegment) {
+	e.segments = append(e.segments, seg)
+	e.total += seg.EndTime - seg.StartTime
+	e.done = append(e.done, e.total)
+}
+
+// Number of segments that start before t
+func (e *sessionEntity) startedBefore(t int) int {
+	return sort.Search(len(e.segments), func(i int) bool { return e.segments[i].StartTime >= t })
+}
+
+// Work the entity has done before time t
+func (e *sessionEntity) executed(t int) int {
+	k := e.startedBefore(t)
+	if k == 0 {
+		return 0
+	}
+	last := e.segments[k-1]
+	return e.done[k-1] - max(last.EndTime-t, 0)
+}
+
+// Whether the entity is on the CPU just after t
+func (e *sessionEntity) runningAt(t int) bool {
+	k := e.startedBefore(t + 1)
+	return k > 0 && t < e.segments[k-1].EndTime
+}
+
+// Whether the entity was on the CPU just before t
+func (e *sessionEntity) runningBefore(t int) bool {
+	k := e.startedBefore(t)
+	return k > 0 && t <= e.segments[k-1].EndTime
+}
+
+// Start of the interval holding t, if any
+func within(ivs []interval, t int) (int, bool) {
+	k := sort.Search(len(ivs), func(i int) bool { return ivs[i].start > t })
+	if k > 0 && t < ivs[k-1].end {
+		return ivs[k-1].start, true
+	}
+	return 0, false
+}
+
+// One step for every instant something changed, or false if the steps
+// would hold more than maxSessionStates entity states
+func sessionSteps(req SimulationRequest, result SimulationResponse, scale int64) ([]SessionStep, bool) {
+	running := runningSegments(result.Timeline)
+	byID := make(map[string]*sessionEntity)
+	var entities []*sessionEntity
+	for _, e := range schedEntities(result.Processes) {
+		if result.Processes[e.owner].Abandoned {
+			continue
+		}
+		se := &sessionEntity{schedEntity: e}
+		byID[e.id] = se
+		entities = append(entities, se)
+	}
+	for _, seg := range running {
+		if e := byID[seg.ProcessID]; e != nil {
+			e.add(seg)
+		}
+	}
+	states, _ := stateIntervals(result.Processes, running)
+	for _, st := range states {
+		if e := byID[st.id]; e != nil {
+			e.ready, e.blocked = st.ready, st.blocked
+		}
+	}
+
+	// Every instant something can change
+	instants := make(map[int]bool)
+	for _, e := range entities {
+		instants[e.arrival] = true
+		for _, seg := range e.segments {
+			instants[seg.StartTime], instants[seg.EndTime] = true, true
+		}
+		for _, iv := range e.blocked {
+			instants[iv.start], instants[iv.end] = true, true
+		}
+	}
+	throttled := make(map[int][]string)
+	for _, seg := range result.Timeline {
+		if seg.Kind != "" {
+			instants[seg.StartTime] = true
+		}
+		if seg.Kind == "throttled" {
+			throttled[seg.StartTime] = append(throttled[seg.StartTime], "cgroup "+seg.Cgroup+" is throttled")
+		}
+	}
+	// Every step lists every entity, so that is what the session holds
+	if len(instants)*len(entities) > maxSessionStates {
+		return nil, false
+	}
+	times := make([]int, 0, len(instants))
+	for t := range instants {
+		times = append(times, t)
+	}
+	sort.Ints(times)
+
+	x := explainer{req: req, result: result, scale: scale, entities: entities, throttled: throttled}
+	steps := make([]SessionStep, 0, len(times))
+	for _, t := range times {
+		step := x.step(t)
+		// Segment boundaries where nothing changed are not steps
+		if n := len(steps); n > 0 && len(step.Events) == 0 && sameState(steps[n-1], step) {
+			continue
+		}
+		steps = append(steps, step)
+	}
+	if len(steps) == 0 {
+		// Every job was abandoned
+		steps = append(steps, SessionStep{Running: []string{}, Ready: []string{}, Reason: "Nothing ran"})
+	}
+	return steps, true
+}
+
+func sameState(a, b SessionStep) bool {
+	return strings.Join(a.Running, ",") == strings.Join(b.Running, ",") &&
+		strings.Join(a.Ready, ",") == strings.Join(b.Ready, ",") &&
+		strings.Join(a.Blocked, ",") == strings.Join(b.Blocked, ",")
+}
+
+// explainer builds steps and says why each decision was made
+type explainer struct {
+	req      SimulationRequest
+	result   SimulationResponse
+	scale    int64
+	entities []*sessionEntity
+
+	throttled map[int][]string // Throttling events by time
+}
+
+// The state at time t
+func (x *explainer) step(t int) SessionStep {
+	step := SessionStep{Time: t, Running: []string{}, Ready: []string{}, Remaining: []RemainingWork{}}
+	var started, stopped []*sessionEntity
+	var ready []*sessionEntity
+	readySince := make(map[string]int)
+	for _, e := range x.entities {
+		left := e.total - e.executed(t)
+		if e.arrival == t {
+			step.Events = append(step.Events, e.id+" arrives")
+		}
+		if e.arrival <= t && left > 0 {
+			step.Remaining = append(step.Remaining, RemainingWork{ID: e.id, Remaining: left})
+		}
+
+		now, before := e.runningAt(t), e.runningBefore(t)
+		switch {
+		case now:
+			step.Running = append(step.Running, e.id)
+			if !before {
+				started = append(started, e)
+			}
+		case before:
+			stopped = append(stopped, e)
+		}
+		if since, ok := within(e.ready, t); ok {
+			ready = append(ready, e)
+			readySince[e.id] = since
+		}
+		if _, ok := within(e.blocked, t); ok {
+			step.Blocked = append(step.Blocked, e.id)
+		}
+		k := sort.Search(len(e.blocked), func(i int) bool { return e.blocked[i].start >= t })
+		if k > 0 && e.blocked[k-1].end == t {
+			step.Events = append(step.Events, e.id+" wakes up")
+		}
+		if k < len(e.blocked) && e.blocked[k].start == t {
+			step.Events = append(step.Events, e.id+" blocks "+x.blockCause(e, t))
+		}
+	}
+	for _, e := range stopped {
+		if e.total-e.executed(t) == 0 {
+			step.Events = append(step.Events, e.id+" finishes")
+		}
+	}
+	step.Events = append(step.Events, x.throttled[t]...)
+
+	// Ready queue in the order processes joined it
+	sort.SliceStable(ready, func(a, b int) bool { return readySince[ready[a].id] < readySince[ready[b].id] })
+	for _, e := range ready {
+		step.Ready = append(step.Ready, e.id)
+	}
+	step.Reason = x.reason(t, started, stopped, ready, len(step.Running) > 0)
+	return step
+}
+
+// What an entity blocks on at time t
+func (x *explainer) blockCause(e *sessionEntity, t int) string {
+	done := e.executed(t)
+	for _, io := range e.ioBursts {
+		if io.Offset == done {
+			return "for I/O"
+		}
+	}
+	return "waiting for its children"
+}
+
+// Why the CPU went where it did at time t
+func (x *explainer) reason(t int, started, stopped, ready []*sessionEntity, busy bool) string {
+	if len(started) == 0 {
+		switch {
+		case busy:
+			return x.keepsRunning(t, ready)
+		case len(ready) > 0:
+			return "CPU idle: the ready processes cannot run yet"
+		case x.allDone(t):
+			return "All processes have finished"
+		}
+		return "CPU idle: nothing is ready"
+	}
+
+	// A stopped entity with work left and back in the ready queue was
+	// preempted
+	var preempted []*sessionEntity
+	for _, e := range stopped {
+		if e.total-e.executed(t) > 0 && contains(ready, e) {
+			preempted = append(preempted, e)
+		}
+	}
+	var reasons []string
+	for _, s := range started {
+		var displaced *sessionEntity
+		displaced, preempted = x.displaced(t, s, preempted)
+		reasons = append(reasons, x.dispatch(t, s, displaced, ready))
+	}
+	return strings.Join(reasons, "; ")
+}
+
+// The preempted entity s took the place of, if any, and the ones left for
+// the other entities that started: one the algorithm ranks s ahead of, else
+// the first one
+func (x *explainer) displaced(t int, s *sessionEntity, preempted []*sessionEntity) (*sessionEntity, []*sessionEntity) {
+	if len(preempted) == 0 {
+		return nil, nil
+	}
+	pick := 0
+	for i, d := range preempted {
+		if x.ahead(t, s, d) {
+			pick = i
+			break
+		}
+	}
+	d := preempted[pick]
+	return d, append(preempted[:pick:pick], preempted[pick+1:]...)
+}
+
+// Whether the algorithm ranks a ahead of or level with b at t
+func (x *explainer) ahead(t int, a, b *sessionEntity) bool {
+	if x.req.Algorithm == "Linux" {
+		return !x.linuxBefore(t, b, a)
+	}
+	key, _, ok := x.rule()
+	if !ok || !x.keyed(a) || !x.keyed(b) {
+		return false
+	}
+	return !x.beats(key(b, t), key(a, t))
+}
+
+func contains(list []*sessionEntity, e *sessionEntity) bool {
+	for _, other := range list {
+		if other == e {
+			return true
+		}
+	}
+	return false
+}
+
+func (x *explainer) allDone(t int) bool {
+	for _, e := range x.entities {
+		if e.arrival > t || e.total-e.executed(t) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Why s was dispatched at t, displacing d if it is set
+func (x *explainer) dispatch(t int, s, d *sessionEntity, ready []*sessionEntity) string {
+	if x.req.Algorithm == "Linux" {
+		return x.linuxDispatch(t, s, d, ready)
+	}
+	key, name, ok := x.rule()
+	ok = ok && x.keyed(s) && (d == nil || x.keyed(d))
+	if d != nil {
+		switch {
+		case ok && x.preemptive() && x.beats(key(s, t), key(d, t)):
+			return fmt.Sprintf("%s preempts %s because %s %s %s %s", s.id, d.id,
+				name, x.format(key(s, t)), x.comparison(), x.format(key(d, t)))
+		case x.roundRobin():
+			return fmt.Sprintf("%s's time slice ran out; %s is next in the ready queue", d.id, s.id)
+		case ok && x.preemptive() && key(s, t) == key(d, t):
+			return fmt.Sprintf("%s preempts %s: %s %s is a tie, broken by %s", s.id, d.id,
+				name, x.format(key(s, t)), strings.Join(x.result.TieBreaker, ", then "))
+		}
+		return fmt.Sprintf("%s preempts %s under %s", s.id, d.id, x.req.Algorithm)
+	}
+
+	if !ok {
+		if x.roundRobin() {
+			return s.id + " is next in the ready queue"
+		}
+		return s.id + " runs next under " + x.req.Algorithm
+	}
+	// Compare with whoever else was ready
+	var others []string
+	tie := false
+	for _, e := range ready {
+		if e == s || !x.keyed(e) {
+			continue
+		}
+		others = append(others, fmt.Sprintf("%s %s", e.id, x.format(key(e, t))))
+		tie = tie || key(e, t) == key(s, t)
+	}
+	text := fmt.Sprintf("%s runs next with %s %s", s.id, name, x.format(key(s, t)))
+	if len(others) > 0 {
+		text += " (others: " + strings.Join(others, ", ") + ")"
+	}
+	switch {
+	case tie && x.roundRobin():
+		text += "; a band takes turns in ready-queue order"
+	case tie:
+		text += "; tie broken by " + strings.Join(x.result.TieBreaker, ", then ")
+	}
+	return text
+}
+
+// Why the running process was not preempted at t
+func (x *explainer) keepsRunning(t int, ready []*sessionEntity) string {
+	var current *sessionEntity
+	for _, e := range x.entities {
+		if e.runningAt(t) {
+			current = e
+			break
+		}
+	}
+	if current == nil {
+		return "The CPU stays busy"
+	}
+	if len(ready) == 0 {
+		return current.id + " keeps running"
+	}
+	if x.req.Algorithm == "Linux" {
+		return x.linuxKeepsRunning(t, current, ready)
+	}
+
+	key, name, ok := x.rule()
+	var best *sessionEntity
+	for _, e := range ready {
+		if ok && x.keyed(e) && (best == nil || x.beats(key(e, t), key(best, t))) {
+			best = e
+		}
+	}
+	switch {
+	case ok && x.preemptive() && x.keyed(current) && best != nil && key(best, t) != key(current, t):
+		return fmt.Sprintf("%s keeps running: %s %s is not beaten by %s's %s",
+			current.id, name, x.format(key(current, t)), best.id, x.format(key(best, t)))
+	case x.roundRobin():
+		return current.id + " keeps running: its time slice has time left"
+	case !ok || !x.keyed(current) || best == nil:
+		return current.id + " keeps running"
+	case !x.preemptive():
+		return current.id + " keeps running: " + x.req.Algorithm + " does not preempt"
+	}
+	return fmt.Sprintf("%s keeps running: %s %s is not beaten by %s's %s",
+		current.id, name, x.format(key(current, t)), best.id, x.format(key(best, t)))
+}
+
+// The key the algorithm picks by and its name, for algorithms that pick by
+// one
+func (x *explainer) rule() (func(e *sessionEntity, t int) int, string, bool) {
+	remaining := func(e *sessionEntity, t int) int { return e.total - e.executed(t) }
+	switch x.req.Algorithm {
+	case "SJF", "LJF":
+		if x.req.IsPreemptive {
+			return remaining, "remaining", true
+		}
+		return remaining, "burst", true
+	case "Priority", "PriorityRR":
+		return func(e *sessionEntity, t int) int { return x.result.Processes[e.owner].Priority }, "priority", true
+	case "FCFS", "BatchFCFS":
+		return func(e *sessionEntity, t int) int { return e.arrival }, "arrival", true
+	case "RM":
+		return func(e *sessionEntity, t int) int { return x.result.Processes[e.owner].Period }, "period", true
+	case "EDF":
+		// Absolute deadline of the job, relative to its release
+		return func(e *sessionEntity, t int) int {
+			p := x.result.Processes[e.owner]
+			if p.Deadline > 0 {
+				return p.ArrivalTime + p.Deadline
+			}
+			return p.ArrivalTime + p.Period
+		}, "deadline", true
+	}
+	return nil, "", false
+}
+
+// Whether the rule applies to e: RM and EDF order periodic jobs only, and
+// aperiodic ones run in the background or under the server
+func (x *explainer) keyed(e *sessionEntity) bool {
+	switch x.req.Algorithm {
+	case "RM", "EDF":
+		return x.result.Processes[e.owner].Period > 0
+	}
+	return true
+}
+
+// Whether the key a beats b under the algorithm
+func (x *explainer) beats(a, b int) bool {
+	switch x.comparison() {
+	case ">":
+		return a > b
+	}
+	return a < b
+}
+
+// The relation a winning key has to a losing one
+func (x *explainer) comparison() string {
+	switch {
+	case x.req.Algorithm == "LJF":
+		return ">"
+	case (x.req.Algorithm == "Priority" || x.req.Algorithm == "PriorityRR") && x.req.PriorityOrder == "higher-first":
+		return ">"
+	}
+	return "<"
+}
+
+// Whether a ready process that beats the running one takes the CPU. RM and
+// EDF always preempt, and PriorityRR does for a higher band.
+func (x *explainer) preemptive() bool {
+	switch x.req.Algorithm {
+	case "SJF", "LJF", "Priority":
+		return x.req.IsPreemptive
+	case "RM", "EDF", "PriorityRR":
+		return true
+	}
+	return false
+}
+
+func (x *explainer) roundRobin() bool {
+	switch x.req.Algorithm {
+	case "RR", "DynamicRR", "SelfishRR", "VRR", "PriorityRR":
+		return true
+	}
+	return false
+}
+
+// A key for a message: times in the time unit, priorities as they are
+func (x *explainer) format(v int) string {
+	if x.req.Algorithm == "Priority" || x.req.Algorithm == "PriorityRR" {
+		return fmt.Sprint(v)
+	}
+	return decimalString(new(big.Rat).SetFrac64(int64(v), x.scale), x.req.TimeResolution)
+}
+
+// Linux orders by scheduling class, then real-time priority or vruntime,
+// so it is explained by those rather than by a single key
+
+// vruntime of a fair-class entity at t: where it was last placed, plus what
+// it has gained running since
+func (x *explainer) vruntime(t int, e *sessionEntity) int64 {
+	var placed vruntimePoint
+	for _, pt := range x.result.vruntimes[e.id] {
+		if pt.time > t {
+			break
+		}
+		placed = pt
+	}
+	ran := e.executed(t) - e.executed(placed.time)
+	return placed.vruntime + int64(ran)*vruntimeCharge(x.result.Processes[e.owner])
+}
+
+// Whether a runs ahead of b at t, like linuxBefore in the engine
+func (x *explainer) linuxBefore(t int, a, b *sessionEntity) bool {
+	pa, pb := x.result.Processes[a.owner], x.result.Processes[b.owner]
+	if ca, cb := schedClass(pa), schedClass(pb); ca != cb {
+		return ca < cb
+	}
+	if isRealtime(pa) {
+		return pa.RTPriority > pb.RTPriority
+	}
+	return x.vruntime(t, a) < x.vruntime(t, b)
+}
+
+// An entity's standing, such as "SCHED_FIFO rtPriority 50" or
+// "SCHED_OTHER vruntime 2.5"
+func (x *explainer) linuxKey(t int, e *sessionEntity) string {
+	p := x.result.Processes[e.owner]
+	if isRealtime(p) {
+		return fmt.Sprintf("%s rtPriority %d", p.Policy, p.RTPriority)
+	}
+	return policyName(p) + " vruntime " + x.formatVruntime(x.vruntime(t, e))
+}
+
+// Why a runs ahead of b at t
+func (x *explainer) linuxWhy(t int, a, b *sessionEntity) string {
+	pa, pb := x.result.Processes[a.owner], x.result.Processes[b.owner]
+	switch {
+	case schedClass(pa) != schedClass(pb):
+		return fmt.Sprintf("%s outranks %s", policyName(pa), policyName(pb))
+	case isRealtime(pa):
+		return fmt.Sprintf("rtPriority %d > %d", pa.RTPriority, pb.RTPriority)
+	}
+	return fmt.Sprintf("vruntime %s < %s", x.formatVruntime(x.vruntime(t, a)), x.formatVruntime(x.vruntime(t, b)))
+}
+
+func (x *explainer) linuxDispatch(t int, s, d *sessionEntity, ready []*sessionEntity) string {
+	if d != nil {
+		if x.linuxBefore(t, s, d) {
+			return fmt.Sprintf("%s preempts %s because %s", s.id, d.id, x.linuxWhy(t, s, d))
+		}
+		return fmt.Sprintf("%s's time slice ran out; %s is next with %s", d.id, s.id, x.linuxKey(t, s))
+	}
+	var others []string
+	for _, e := range ready {
+		if e != s {
+			others = append(others, e.id+" "+x.linuxKey(t, e))
+		}
+	}
+	text := fmt.Sprintf("%s runs next with %s", s.id, x.linuxKey(t, s))
+	if len(others) > 0 {
+		text += " (others: " + strings.Join(others, ", ") + ")"
+	}
+	return text
+}
+
+func (x *explainer) linuxKeepsRunning(t int, current *sessionEntity, ready []*sessionEntity) string {
+	best := ready[0]
+	for _, e := range ready[1:] {
+		if x.linuxBefore(t, e, best) {
+			best = e
+		}
+	}
+	p, pb := x.result.Processes[current.owner], x.result.Processes[best.owner]
+	switch {
+	case x.linuxBefore(t, current, best):
+		return fmt.Sprintf("%s keeps running: %s beats %s's %s",
+			current.id, x.linuxKey(t, current), best.id, x.linuxKey(t, best))
+	case p.Policy == schedFIFO && pb.RTPriority == p.RTPriority:
+		return current.id + " keeps running: SCHED_FIFO runs until it blocks or finishes"
+	case x.linuxBefore(t, best, current) && (isRealtime(pb) || schedClass(pb) < schedClass(p)):
+		// A higher class or real-time priority would have preempted, so it
+		// is held back, such as by real-time throttling
+		return current.id + " keeps running: " + best.id + " cannot run yet"
+	}
+	return current.id + " keeps running: its time slice has time left"
+}
+
+// vruntime in the time unit, for a task of nice 0, to two more places
+// than the resolution since weights make it fractional
+func (x *explainer) formatVruntime(v int64) string {
+	return decimalString(new(big.Rat).SetFrac64(v, niceToWeight[20]*x.scale), x.req.TimeResolution+2)
+}
+
+func policyName(p Process) string {
+	if p.Policy == "" {
+		return schedOther
+	}
+	return p.Policy
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// Decisions are explained by the rule of the algorithm that made them
+func TestSessionReasons(t *testing.T) {
+	rt := []Process{{ID: "T1", BurstTime: 3, Period: 10}, {ID: "T2", ArrivalTime: 1, BurstTime: 1, Period: 4}}
+	cases := []struct {
+		req  SimulationRequest
+		time int
+		want string
+	}{
+		{SimulationRequest{Algorithm: "EDF", Horizon: 10, Processes: rt}, 1, "T2#0 preempts T1#0 because deadline 5 < 10"},
+		{SimulationRequest{Algorithm: "RM", Horizon: 10, Processes: rt}, 1, "T2#0 preempts T1#0 because period 4 < 10"},
+		{SimulationRequest{Algorithm: "PriorityRR", TimeQuantum: 2, Processes: []Process{
+			{ID: "P1", BurstTime: 4, Priority: 2},
+			{ID: "P2", ArrivalTime: 1, BurstTime: 3, Priority: 1},
+			{ID: "P3", ArrivalTime: 1, BurstTime: 3, Priority: 1},
+		}}, 1, "P2 preempts P1 because priority 1 < 2"},
+		{SimulationRequest{Algorithm: "PriorityRR", TimeQuantum: 2, Processes: []Process{
+			{ID: "P1", BurstTime: 4, Priority: 2},
+			{ID: "P2", ArrivalTime: 1, BurstTime: 3, Priority: 1},
+			{ID: "P3", ArrivalTime: 1, BurstTime: 3, Priority: 1},
+		}}, 3, "P2's time slice ran out; P3 is next in the ready queue"},
+		{SimulationRequest{Algorithm: "Linux", TimeQuantum: 2, Processes: []Process{
+			{ID: "P1", BurstTime: 4},
+			{ID: "P2", BurstTime: 4, Nice: 5},
+			{ID: "P3", ArrivalTime: 3, BurstTime: 2, Policy: schedFIFO, RTPriority: 10},
+		}}, 3, "P3 preempts P2 because SCHED_FIFO outranks SCHED_OTHER"},
+		{SimulationRequest{Algorithm: "Linux", TimeQuantum: 2, Processes: []Process{
+			{ID: "P1", BurstTime: 4},
+			{ID: "P2", BurstTime: 4, Nice: 5},
+			{ID: "P3", ArrivalTime: 3, BurstTime: 2, Policy: schedFIFO, RTPriority: 10},
+		}}, 5, "P1 runs next with SCHED_OTHER vruntime 2 (others: P2 SCHED_OTHER vruntime 3.06)"},
+	}
+	for _, tc := range cases {
+		if errs := validateRequest(tc.req, nil); len(errs) > 0 {
+			t.Fatalf("%s: %v", tc.req.Algorithm, errs)
+		}
+		result, _ := simulate(tc.req, 1)
+		got := ""
+		steps, _ := sessionSteps(tc.req, result, 1)
+		for _, step := range steps {
+			if step.Time == tc.time {
+				got = step.Reason
+			}
+		}
+		if got != tc.want {
+			t.Errorf("%s at %d: got %q, want %q", tc.req.Algorithm, tc.time, got, tc.want)
+		}
+	}
+}
+
+// A process that starts is matched with the entity it preempted, not with
+// whichever stopped entity happens to share its position
+func TestReasonMatchesPreemptedEntity(t *testing.T) {
+	entity := func(id string, owner, arrival int, segments ...TimelineSegment) *sessionEntity {
+		e := &sessionEntity{schedEntity: schedEntity{id: id, owner: owner, arrival: arrival}}
+		for _, seg := range segments {
+			seg.ProcessID = id
+			e.add(seg)
+		}
+		return e
+	}
+	finished := entity("A", 0, 0, TimelineSegment{StartTime: 0, EndTime: 2})
+	preempted := entity("B", 1, 0, TimelineSegment{StartTime: 0, EndTime: 2}, TimelineSegment{StartTime: 3, EndTime: 5})
+	started := entity("C", 2, 2, TimelineSegment{StartTime: 2, EndTime: 3})
+	x := explainer{
+		req:      SimulationRequest{Algorithm: "SJF", IsPreemptive: true},
+		result:   SimulationResponse{Processes: make([]Process, 3)},
+		scale:    1,
+		entities: []*sessionEntity{finished, preempted, started},
+	}
+
+	got := x.reason(2, []*sessionEntity{started}, []*sessionEntity{finished, preempted}, []*sessionEntity{preempted}, true)
+	if want := "C preempts B because remaining 1 < 2"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+// Sessions too big to hold are refused, and the largest allowed ones build
+// in time linear in their steps
+func TestSessionLimits(t *testing.T) {
+	rr := func(n, burst int) SimulationRequest {
+		req := SimulationRequest{Algorithm: "RR", TimeQuantum: 1}
+		for i := range n {
+			req.Processes = append(req.Processes, Process{ID: fmt.Sprintf("P%d", i+1), BurstTime: burst})
+		}
+		return req
+	}
+
+	// Every process in every step: 20,000 steps of 2000 processes
+	req := rr(maxSessionEntities, 10)
+	result, _ := simulate(req, 1)
+	if _, ok := sessionSteps(req, result, 1); ok {
+		t.Error("session of 2000 processes over 20000 steps was accepted")
+	}
+	if errs := validateSession(rr(2, maxSessionTicks)); len(errs) == 0 {
+		t.Error("session over the tick limit was accepted")
+	}
+
+	// Few processes and many steps: each step looks segments up rather than
+	// scanning them
+	req = rr(2, maxSessionTicks/4)
+	if errs := validateSession(req); len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	result, _ = simulate(req, 1)
+	start := time.Now()
+	steps, ok := sessionSteps(req, result, 1)
+	if want := maxSessionTicks/2 + 1; !ok || len(steps) != want {
+		t.Fatalf("got %d steps, want %d", len(steps), want)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("%d steps took %v", len(steps), elapsed)
+	}
+}
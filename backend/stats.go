@@ -0,0 +1,276 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Distribution describes how a per-process time is spread across the
+// processes, for when the average hides a long tail. Percentiles use the
+// nearest-rank method, so each one is a time some process actually saw.
+type Distribution struct {
+	Count  int     `json:"count"`
+	Min    int     `json:"min" time:"ticks"`
+	Max    int     `json:"max" time:"ticks"`
+	Mean   float64 `json:"mean" time:"average"`
+	Median float64 `json:"median" time:"average"`
+	P90    int     `json:"p90" time:"ticks"`
+	P95    int     `json:"p95" time:"ticks"`
+	P99    int     `json:"p99" time:"ticks"`
+
+	// Population standard deviation, and the same over the mean
+	// (coefficient of variation, 0 when the mean is 0)
+	StdDev float64 `json:"stdDev" time:"average"`
+	CV     float64 `json:"cv"`
+
+	Histogram []HistogramBucket `json:"histogram,omitempty"`
+}
+
+// Distributions of the three per-process times
+type Distributions struct {
+	WaitingTime    *Distribution `json:"waitingTime"`
+	TurnaroundTime *Distribution `json:"turnaroundTime"`
+	ResponseTime   *Distribution `json:"responseTime"`
+}
+
+// HistogramConfig asks for a histogram of one metric, either with buckets
+// of a fixed Width starting at 0 or with explicit bucket Edges. With edges
+// e0 < e1 < ... < en the buckets are [e0, e1), ..., [en-1, en), plus open
+// buckets for anything below e0 or from en on.
+type HistogramConfig struct {
+	Width int   `json:"width,omitempty" time:"ticks"`
+	Edges []int `json:"edges,omitempty" time:"ticks"`
+}
+
+// HistogramBucket counts the processes with From <= time < To. From or To
+// is missing for the open buckets at either end.
+type HistogramBucket struct {
+	From  *int `json:"from,omitempty" time:"ticks"`
+	To    *int `json:"to,omitempty" time:"ticks"`
+	Count int  `json:"count"`
+}
+
+// Metrics a histogram can be asked for
+var histogramMetrics = []string{"waitingTime", "turnaroundTime", "responseTime"}
+
+// Check the histogram options of a request
+func validateHistograms(histograms map[string]HistogramConfig) []FieldError {
+	var errs []FieldError
+	// Visit metrics in order so errors come out the same way every time
+	names := make([]string, 0, len(histograms))
+	for name := range histograms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		h := histograms[name]
+		path := "histograms." + name
+		known := false
+		for _, m := range histogramMetrics {
+			known = known || m == name
+		}
+		if !known {
+			errs = append(errs, fieldError(path, codeUnknown, "Histograms are available for waitingTime, turnaroundTime and responseTime"))
+			continue
+		}
+		switch {
+		case h.Width < 0:
+			errs = append(errs, fieldError(path+".width", codeNegative, "Bucket width cannot be negative"))
+		case h.Width > 0 && len(h.Edges) > 0:
+			errs = append(errs, fieldError(path, codeUnsupported, "Give either a bucket width or bucket edges, not both"))
+		case h.Width == 0 && len(h.Edges) == 0:
+			errs = append(errs, fieldError(path, codeRequired, "A histogram needs a bucket width or bucket edges"))
+		}
+		for i := 1; i < len(h.Edges); i++ {
+			if h.Edges[i] <= h.Edges[i-1] {
+				errs = append(errs, fieldError(fmt.Sprintf("%s.edges[%d]", path, i), codeOutOfRange, "Bucket edges must be increasing"))
+			}
+		}
+	}
+	return errs
+}
+
+// Distributions of waiting, turnaround and response time over the
+// processes that ran, with any histograms asked for
+func distributions(processes []Process, histograms map[string]HistogramConfig) *Distributions {
+	var waiting, turnaround, response []int
+	for _, p := range processes {
+		// Abandoned jobs never ran to completion, so they have no times
+		if p.Abandoned {
+			continue
+		}
+		waiting = append(waiting, p.WaitingTime)
+		turnaround = append(turnaround, p.TurnaroundTime)
+		response = append(response, p.ResponseTime)
+	}
+	if len(waiting) == 0 {
+		return nil
+	}
+	return &Distributions{
+		WaitingTime:    distribution(waiting, histograms["waitingTime"]),
+		TurnaroundTime: distribution(turnaround, histograms["turnaroundTime"]),
+		ResponseTime:   distribution(response, histograms["responseTime"]),
+	}
+}
+
+func distribution(values []int, hist HistogramConfig) *Distribution {
+	sorted := make([]int, len(values))
+	copy(sorted, values)
+	sort.Ints(sorted)
+	n := len(sorted)
+
+	d := &Distribution{
+		Count: n,
+		Min:   sorted[0],
+		Max:   sorted[n-1],
+		P90:   percentile(sorted, 90),
+		P95:   percentile(sorted, 95),
+		P99:   percentile(sorted, 99),
+	}
+	if n%2 == 1 {
+		d.Median = float64(sorted[n/2])
+	} else {
+		d.Median = float64(sorted[n/2-1]+sorted[n/2]) / 2
+	}
+
+	total := 0
+	for _, v := range sorted {
+		total += v
+	}
+	d.Mean = float64(total) / float64(n)
+	var squares float64
+	for _, v := range sorted {
+		diff := float64(v) - d.Mean
+		squares += diff * diff
+	}
+	d.StdDev = math.Sqrt(squares / float64(n))
+	if d.Mean != 0 {
+		d.CV = d.StdDev / d.Mean
+	}
+
+	d.Histogram = histogram(sorted, hist)
+	return d
+}
+
+// Nearest-rank percentile of sorted values: the smallest value at least p
+// percent of the values are less than or equal to
+func percentile(sorted []int, p int) int {
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// Bucket counts of sorted values, or nil if no histogram was asked for
+func histogram(sorted []int, hist HistogramConfig) []HistogramBucket {
+	edges := hist.Edges
+	if hist.Width > 0 {
+		// Fixed-width buckets from 0 up past the largest value
+		edges = nil
+		last := sorted[len(sorted)-1]
+		for e := 0; e <= last; e += hist.Width {
+			edges = append(edges, e)
+		}
+		edges = append(edges, edges[len(edges)-1]+hist.Width)
+	}
+	if len(edges) == 0 {
+		return nil
+	}
+
+	var buckets []HistogramBucket
+	k := 0 // Next value to place
+
+	// Open bucket below the first edge, only with explicit edges
+	if hist.Width == 0 {
+		below := HistogramBucket{To: &edges[0]}
+		for k < len(sorted) && sorted[k] < edges[0] {
+			below.Count++
+			k++
+		}
+		buckets = append(buckets, below)
+	}
+	for i := 0; i+1 < len(edges); i++ {
+		b := HistogramBucket{From: &edges[i], To: &edges[i+1]}
+		for k < len(sorted) && sorted[k] < edges[i+1] {
+			b.Count++
+			k++
+		}
+		buckets = append(buckets, b)
+	}
+	if hist.Width == 0 {
+		above := HistogramBucket{From: &edges[len(edges)-1], Count: len(sorted) - k}
+		buckets = append(buckets, above)
+	}
+	return buckets
+}
+
+// Two-sided p-value of a Student t statistic with df degrees of freedom
+func studentTPValue(t, df float64) float64 {
+	return incompleteBeta(df/(df+t*t), df/2, 0.5)
+}
+
+// The t value a two-sided test with df degrees of freedom rejects at the
+// given significance level, found by bisection since the p-value falls as
+// |t| grows
+func studentTQuantile(alpha, df float64) float64 {
+	lo, hi := 0.0, 1e6
+	for i := 0; i < 200 && hi-lo > 1e-12; i++ {
+		mid := (lo + hi) / 2
+		if studentTPValue(mid, df) > alpha {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return (lo + hi) / 2
+}
+
+// Regularized incomplete beta function I_x(a, b), by its continued
+// fraction (Numerical Recipes, 6.4), on whichever side converges faster
+func incompleteBeta(x, a, b float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	if x >= 1 {
+		return 1
+	}
+	la, _ := math.Lgamma(a)
+	lb, _ := math.Lgamma(b)
+	lab, _ := math.Lgamma(a + b)
+	front := math.Exp(lab - la - lb + a*math.Log(x) + b*math.Log(1-x))
+	if x < (a+1)/(a+b+2) {
+		return front * betaFraction(x, a, b) / a
+	}
+	return 1 - front*betaFraction(1-x, b, a)/b
+}
+
+// Continued fraction for the incomplete beta function, by Lentz's method
+func betaFraction(x, a, b float64) float64 {
+	const tiny = 1e-300
+	clamp := func(v float64) float64 {
+		if math.Abs(v) < tiny {
+			return tiny
+		}
+		return v
+	}
+	c, d := 1.0, 1/clamp(1-(a+b)*x/(a+1))
+	h := d
+	for m := 1.0; m <= 300; m++ {
+		// Even step
+		aa := m * (b - m) * x / ((a + 2*m - 1) * (a + 2*m))
+		d = 1 / clamp(1+aa*d)
+		c = clamp(1 + aa/c)
+		h *= d * c
+		// Odd step
+		aa = -(a + m) * (a + b + m) * x / ((a + 2*m) * (a + 2*m + 1))
+		d = 1 / clamp(1+aa*d)
+		c = clamp(1 + aa/c)
+		h *= d * c
+		if math.Abs(d*c-1) < 1e-15 {
+			break
+		}
+	}
+	return h
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Parameter sweeps: one algorithm run over a range of values of a numeric
+// option, with every ranking metric reported as a series over the values.
+// The values are given in the request's time unit for time parameters, and
+// are sent back the same way, so they are kept as exact numbers here rather
+// than tagged as times.
+
+// Most values one sweep may run
+const maxSweepPoints = 200
+
+type SweepRequest struct {
+	SimulationRequest
+	Parameter string `json:"parameter"`
+
+	// Either a list of values or a range from Start to Stop (inclusive)
+	// in steps of Step
+	Values []json.Number `json:"values,omitempty"`
+	Start  json.Number   `json:"start,omitempty"`
+	Stop   json.Number   `json:"stop,omitempty"`
+	Step   json.Number   `json:"step,omitempty"`
+
+	// Metric to optimize, one of the /compare ranking metrics. Lower is
+	// better unless the metric is one where higher is.
+	Optimize string `json:"optimize,omitempty"`
+}
+
+// sweepParameter is an option a sweep can vary
+type sweepParameter struct {
+	isTime bool
+	min    int // Smallest allowed value, in ticks for time parameters
+	apply  func(req *SimulationRequest, v int)
+}
+
+var sweepParameters = map[string]sweepParameter{
+	"timeQuantum": {true, 1, func(req *SimulationRequest, v int) { req.TimeQuantum = v }},
+	"selfishRR.newRate": {false, 0, func(req *SimulationRequest, v int) {
+		rates := selfishRates(req)
+		rates.NewRate = v
+		req.SelfishRR = &rates
+	}},
+	"selfishRR.acceptedRate": {false, 0, func(req *SimulationRequest, v int) {
+		rates := selfishRates(req)
+		rates.AcceptedRate = v
+		req.SelfishRR = &rates
+	}},
+	"kernelThreads": {false, 1, func(req *SimulationRequest, v int) { req.KernelThreads = v }},
+	"nodes":         {false, 1, func(req *SimulationRequest, v int) { req.Nodes = v }},
+	"rtRuntime":     {true, 0, func(req *SimulationRequest, v int) { req.RTRuntime = v }},
+	"rtPeriod":      {true, 0, func(req *SimulationRequest, v int) { req.RTPeriod = v }},
+}
+
+// Selfish RR rates a request runs with, as a copy that can be changed
+func selfishRates(req *SimulationRequest) SelfishRRConfig {
+	if req.SelfishRR != nil {
+		return *req.SelfishRR
+	}
+	return defaultSelfishRR
+}
+
+type SweepResponse struct {
+	Parameter string         `json:"parameter"`
+	Values    []json.Number  `json:"values"`
+	Series    []MetricSeries `json:"series"`
+	Optimum   *SweepOptimum  `json:"optimum,omitempty"`
+}
+
+// MetricSeries is one metric at every value of the parameter
+type MetricSeries struct {
+	Metric string    `json:"metric"`
+	Better string    `json:"better"` // "lower" or "higher"
+	Values []float64 `json:"values"`
+}
+
+// SweepOptimum is the parameter value with the best objective; ties go to
+// the value run first
+type SweepOptimum struct {
+	Objective string      `json:"objective"`
+	Value     json.Number `json:"value"`
+	Metric    float64     `json:"metricValue"`
+}
+
+func handleSweep(c *gin.Context) {
+	var req SweepRequest
+	scale, raw, ok := decodeBody(c, &req)
+	if !ok {
+		return
+	}
+	values, errs := sweepValues(req, scale)
+	if len(errs) == 0 {
+		errs = validateSweep(req, values, raw)
+	}
+	if len(errs) > 0 {
+		rejectRequest(c, errs)
+		return
+	}
+
+	respond(c, sweep(req, values, scale), req.TimeResolution)
+}
+
+// The values a sweep runs, in ticks for time parameters
+func sweepValues(req SweepRequest, scale int64) ([]int, []FieldError) {
+	param, ok := sweepParameters[req.Parameter]
+	if !ok {
+		names := make([]string, 0, len(sweepParameters))
+		for name := range sweepParameters {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		if req.Parameter == "" {
+			return nil, []FieldError{fieldError("parameter", codeRequired, "Parameter is required: one of "+strings.Join(names, ", "))}
+		}
+		return nil, []FieldError{fieldError("parameter", codeUnknown, "Unknown sweep parameter; sweepable are "+strings.Join(names, ", "))}
+	}
+
+	var errs []FieldError
+	// One value in ticks, checked against the parameter's minimum
+	convert := func(n json.Number, field string) (int, bool) {
+		if param.isTime {
+			scaled, err := scaleNumber(n, scale, req.Parameter, field)
+			if err != nil {
+				errs = append(errs, *err)
+				return 0, false
+			}
+			n = scaled
+		}
+		v, err := n.Int64()
+		if err != nil {
+			errs = append(errs, fieldError(field, codeInvalidType, req.Parameter+" values must be whole numbers"))
+			return 0, false
+		}
+		return int(v), true
+	}
+
+	var values []int
+	switch {
+	case len(req.Values) > 0 && req.Start != "":
+		errs = append(errs, fieldError("values", codeUnsupported, "Give either a list of values or a range, not both"))
+	case len(req.Values) > 0:
+		for i, n := range req.Values {
+			if v, ok := convert(n, fmt.Sprintf("values[%d]", i)); ok {
+				values = append(values, v)
+			}
+		}
+	case req.Start == "" || req.Stop == "" || req.Step == "":
+		errs = append(errs, fieldError("values", codeRequired, "Give a list of values or a start, stop and step"))
+	default:
+		start, ok1 := convert(req.Start, "start")
+		stop, ok2 := convert(req.Stop, "stop")
+		step, ok3 := convert(req.Step, "step")
+		switch {
+		case !ok1 || !ok2 || !ok3:
+		case step <= 0:
+			errs = append(errs, fieldError("step", codeNotPositive, "Step must be positive"))
+		case stop < start:
+			errs = append(errs, fieldError("stop", codeOutOfRange, "Stop cannot be less than start"))
+		case (stop-start)/step+1 > maxSweepPoints:
+			errs = append(errs, fieldError("step", codeOutOfRange, fmt.Sprintf("A sweep can run at most %d values", maxSweepPoints)))
+		default:
+			for v := start; v <= stop; v += step {
+				values = append(values, v)
+			}
+		}
+	}
+	if len(req.Values) > maxSweepPoints {
+		errs = append(errs, fieldError("values", codeOutOfRange, fmt.Sprintf("A sweep can run at most %d values", maxSweepPoints)))
+	}
+	for i, v := range values {
+		if v < param.min {
+			field := "start"
+			if len(req.Values) > 0 {
+				field = fmt.Sprintf("values[%d]", i)
+			}
+			errs = append(errs, fieldError(field, codeOutOfRange, fmt.Sprintf("%s cannot be less than %s", req.Parameter, sweepValue(param, param.min, scale, req.TimeResolution))))
+			break
+		}
+	}
+	return values, errs
+}
+
+// Check the optimize option and the request as it runs at each value.
+// Errors that every value shares are reported once.
+func validateSweep(req SweepRequest, values []int, raw map[string]interface{}) []FieldError {
+	var errs []FieldError
+	if req.Optimize != "" && findCompareMetric(req.Optimize) == nil {
+		errs = append(errs, fieldError("optimize", codeUnknown, "Unknown objective"))
+	}
+	seen := make(map[FieldError]bool)
+	for _, v := range values {
+		for _, e := range validateRequest(sweepRequest(req, v), raw) {
+			if !seen[e] {
+				seen[e] = true
+				errs = append(errs, e)
+			}
+		}
+	}
+	return errs
+}
+
+// The simulation request at one value of the parameter
+func sweepRequest(req SweepRequest, v int) SimulationRequest {
+	run := req.SimulationRequest
+	run.Processes = cloneProcesses(req.Processes)
+	sweepParameters[req.Parameter].apply(&run, v)
+	return run
+}
+
+// A parameter value as sent back: in the time unit for time parameters
+func sweepValue(param sweepParameter, v int, scale int64, resolution int) json.Number {
+	if !param.isTime {
+		return json.Number(fmt.Sprint(v))
+	}
+	return json.Number(decimalString(new(big.Rat).SetFrac64(int64(v), scale), resolution))
+}
+
+// Run the algorithm at every value, a few at a time, and collect the series
+func sweep(req SweepRequest, values []int, scale int64) SweepResponse {
+	results := make([]SimulationResponse, len(values))
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, runtime.NumCPU())
+	for i, v := range values {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(i, v int) {
+			defer wg.Done()
+			results[i] = simulateValidated(sweepRequest(req, v), scale)
+			<-slots
+		}(i, v)
+	}
+	wg.Wait()
+
+	param := sweepParameters[req.Parameter]
+	response := SweepResponse{Parameter: req.Parameter}
+	for _, v := range values {
+		response.Values = append(response.Values, sweepValue(param, v, scale, req.TimeResolution))
+	}
+	for _, m := range compareMetrics {
+		series := MetricSeries{Metric: m.name, Better: better(m)}
+		for _, r := range results {
+			series.Values = append(series.Values, m.report(r, scale))
+		}
+		response.Series = append(response.Series, series)
+
+		if m.name != req.Optimize {
+			continue
+		}
+		best := 0
+		for i, x := range series.Values {
+			if m.higherBetter && x > series.Values[best] || !m.higherBetter && x < series.Values[best] {
+				best = i
+			}
+		}
+		response.Optimum = &SweepOptimum{Objective: m.name, Value: response.Values[best], Metric: series.Values[best]}
+	}
+	return response
+}
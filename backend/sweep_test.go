@@ -0,0 +1,20 @@
+package main
+
+import "testing"
+
+// Each value runs as its own request and is checked as one: a cluster of
+// 8 nodes fits a 4-node job, one of 2 does not
+func TestSweepValidatesEveryValue(t *testing.T) {
+	req := SweepRequest{Parameter: "nodes"}
+	req.Algorithm = "EASY"
+	req.Processes = []Process{{ID: "J1", BurstTime: 2, Nodes: 4}}
+	for _, tc := range []struct {
+		values   []int
+		rejected bool
+	}{{[]int{8, 4}, false}, {[]int{8, 2}, true}} {
+		errs := validateSweep(req, tc.values, map[string]interface{}{})
+		if rejected := len(errs) > 0; rejected != tc.rejected {
+			t.Errorf("nodes %v: errors %v, want rejected %v", tc.values, errs, tc.rejected)
+		}
+	}
+}
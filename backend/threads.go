@@ -0,0 +1,186 @@
+package main
+
+// Thread is one thread of a multithreaded process. Threads share their
+// process's priority and nice value.
+type Thread struct {
+	ID          string    `json:"id"`
+	StartOffset int       `json:"startOffset,omitempty" time:"ticks"` // Created this long after the process arrives
+	BurstTime   int       `json:"burstTime" time:"ticks"`
+	IOBursts    []IOBurst `json:"ioBursts,omitempty"`
+
+	// Filled in by the simulation
+	ArrivalTime    int `json:"arrivalTime" time:"ticks"`
+	CompletionTime int `json:"completionTime" time:"ticks"`
+	TurnaroundTime int `json:"turnaroundTime" time:"ticks"`
+	WaitingTime    int `json:"waitingTime" time:"ticks"`
+	ResponseTime   int `json:"responseTime" time:"ticks"`
+}
+
+// Check whether any process in the workload is split into threads
+func hasThreads(processes []Process) bool {
+	for _, p := range processes {
+		if len(p.Threads) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func isValidThreadingModel(model string) bool {
+	switch model {
+	case "", "one-to-one", "many-to-one", "many-to-many":
+		return true
+	}
+	return false
+}
+
+// Schedule a workload of multithreaded processes. Every thread becomes its
+// own entity in the step engine, and the threading model decides which of
+// them the kernel can actually see:
+//
+//   - one-to-one: every thread has its own kernel thread and is scheduled
+//     independently
+//   - many-to-one: the kernel sees only the process. A user-level library
+//     runs one thread at a time until it finishes, and a thread blocking on
+//     I/O blocks the whole process
+//   - many-to-many: each process has KernelThreads kernel-thread slots. A
+//     thread holds a slot from the moment it gets one until it finishes,
+//     including while blocked on I/O, and threads without a slot wait
+func runThreaded(req SimulationRequest) SimulationResponse {
+	// Flatten every thread into its own schedulable entity
+	var entities []Process
+	var owner []int     // Index in req.Processes of each entity's process
+	var threadIdx []int // Index in that process's Threads, -1 if single-threaded
+	members := make([][]int, len(req.Processes))
+	for pi, p := range req.Processes {
+		if len(p.Threads) == 0 {
+			members[pi] = append(members[pi], len(entities))
+			entities = append(entities, p)
+			owner = append(owner, pi)
+			threadIdx = append(threadIdx, -1)
+			continue
+		}
+
+		for ti, t := range p.Threads {
+			members[pi] = append(members[pi], len(entities))
+			entities = append(entities, Process{
+				ID:          p.ID + ":" + t.ID,
+				ArrivalTime: p.ArrivalTime + t.StartOffset,
+				BurstTime:   t.BurstTime,
+				Priority:    p.Priority,
+				Nice:        p.Nice,
+				Cgroup:      p.Cgroup,
+				User:        p.User,
+				Policy:      p.Policy,
+				RTPriority:  p.RTPriority,
+				IOBursts:    t.IOBursts,
+			})
+			owner = append(owner, pi)
+			threadIdx = append(threadIdx, ti)
+		}
+	}
+
+	threadReq := req
+	threadReq.Processes = entities
+	e := newEngine(threadReq)
+
+	switch req.ThreadingModel {
+	case "many-to-one":
+		// Thread the user-level library is currently running, per process
+		current := make([]int, len(req.Processes))
+		for i := range current {
+			current[i] = -1
+		}
+
+		e.filters = append(e.filters, func(i int) bool {
+			o := owner[i]
+
+			// A thread blocked in I/O blocks its whole process
+			for _, j := range members[o] {
+				if e.state[j] == stateBlocked {
+					return false
+				}
+			}
+
+			// The library only switches threads once the current one is done
+			if current[o] == -1 || e.state[current[o]] == stateDone {
+				current[o] = i
+			}
+			return current[o] == i
+		})
+	case "many-to-many":
+		slots := req.KernelThreads
+		if slots <= 0 {
+			slots = 1
+		}
+		bound := make([]bool, len(entities))
+
+		e.filters = append(e.filters, func(i int) bool {
+			if bound[i] {
+				return true
+			}
+
+			// Take a free kernel-thread slot if the process has one
+			used := 0
+			for _, j := range members[owner[i]] {
+				if bound[j] && e.state[j] != stateDone {
+					used++
+				}
+			}
+			if used < slots {
+				bound[i] = true
+				return true
+			}
+			return false
+		})
+	}
+
+	e.run()
+
+	// Report per thread and roll threads up into their process
+	procs := make([]Process, len(req.Processes))
+	copy(procs, req.Processes)
+	for pi := range procs {
+		if len(procs[pi].Threads) == 0 {
+			procs[pi] = e.procs[members[pi][0]]
+			continue
+		}
+
+		threads := make([]Thread, len(procs[pi].Threads))
+		copy(threads, procs[pi].Threads)
+		procs[pi].Threads = threads
+		procs[pi].BurstTime = 0
+		procs[pi].CompletionTime = 0
+		procs[pi].WaitingTime = 0
+		firstStart := -1
+
+		for _, j := range members[pi] {
+			t := &procs[pi].Threads[threadIdx[j]]
+			ent := e.procs[j]
+			t.ArrivalTime = ent.ArrivalTime
+			t.CompletionTime = ent.CompletionTime
+			t.TurnaroundTime = ent.TurnaroundTime
+			t.WaitingTime = ent.WaitingTime
+			t.ResponseTime = ent.ResponseTime
+
+			// A process waits as long as all its threads together, which
+			// can be more than its turnaround minus its burst
+			procs[pi].BurstTime += ent.BurstTime
+			procs[pi].WaitingTime += ent.WaitingTime
+			if ent.CompletionTime > procs[pi].CompletionTime {
+				procs[pi].CompletionTime = ent.CompletionTime
+			}
+			if firstStart == -1 || ent.StartTime < firstStart {
+				firstStart = ent.StartTime
+			}
+		}
+
+		procs[pi].TurnaroundTime = procs[pi].CompletionTime - procs[pi].ArrivalTime
+		procs[pi].StartTime = firstStart
+		procs[pi].ResponseTime = firstStart - procs[pi].ArrivalTime
+	}
+
+	response := e.response()
+	response.Processes = procs
+	return response
+}
@@ -0,0 +1,30 @@
+package main
+
+import "testing"
+
+func TestThreadingModels(t *testing.T) {
+	// T1 blocks on I/O from 1 to 4
+	threads := `"processes": [{"id": "P", "threads": [{"id": "T1", "burstTime": 2, "ioBursts": [{"offset": 1, "duration": 3}]}, {"id": "T2", "burstTime": 2}]}, {"id": "Q", "burstTime": 3}]}`
+	runScheduleCases(t, []scheduleCase{
+		{
+			// The whole process blocks with T1, so T2 cannot run until T1
+			// is done. P waits 0 + 5 over its threads, more than its
+			// turnaround of 7 minus its burst of 4
+			name:     "many-to-one",
+			body:     `{"algorithm": "FCFS", "threadingModel": "many-to-one", ` + threads,
+			timeline: "P:T1 0-1, Q 1-4, P:T1 4-5, P:T2 5-7",
+			times:    "P 5/7, Q 1/4",
+			check: func(t *testing.T, r SimulationResponse) {
+				if th := r.Processes[0].Threads; th[0].WaitingTime != 0 || th[1].WaitingTime != 5 {
+					t.Errorf("thread waits %d and %d, want 0 and 5", th[0].WaitingTime, th[1].WaitingTime)
+				}
+			},
+		},
+		{
+			name:     "one-to-one",
+			body:     `{"algorithm": "FCFS", "threadingModel": "one-to-one", ` + threads,
+			timeline: "P:T1 0-1, P:T2 1-3, Q 3-6, P:T1 6-7",
+			times:    "P 3/7, Q 3/6",
+		},
+	})
+}
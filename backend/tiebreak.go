@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Tie-breaking between processes that an algorithm's own rule cannot tell
+// apart, such as two jobs with the same burst time under SJF or two
+// arrivals at the same instant under FCFS. A tie-breaker is a chain of
+// keys tried in order:
+//
+//   - "arrival": earlier arrival time first
+//   - "id": smaller process ID first, comparing runs of digits as numbers
+//     so that P2 comes before P10
+//   - "input": earlier position in the request first
+//
+// Input order always settles whatever the chain leaves tied, so results
+// never depend on how a particular function happens to scan its slice.
+// The chain only decides who is picked next: a process tied with the
+// running one on the algorithm's own key never preempts it.
+type tieBreaker []string
+
+// Chain used when the request does not set one: the textbook convention
+var defaultTieBreaker = tieBreaker{"arrival", "id", "input"}
+
+// Check that every key in a tie-breaker chain is known and used only once
+func validateTieBreaker(chain []string) []FieldError {
+	var errs []FieldError
+	seen := make(map[string]bool)
+	for i, key := range chain {
+		field := fmt.Sprintf("tieBreaker[%d]", i)
+		switch key {
+		case "arrival", "id", "input":
+		default:
+			errs = append(errs, fieldError(field, codeUnknown, "Unknown tie-breaker key"))
+			continue
+		}
+		if seen[key] {
+			errs = append(errs, fieldError(field, codeDuplicate, "Tie-breaker key listed twice"))
+		}
+		seen[key] = true
+	}
+	return errs
+}
+
+// Tie-breaker chain a request asked for, or the default
+func requestTieBreaker(req SimulationRequest) tieBreaker {
+	if len(req.TieBreaker) == 0 {
+		return defaultTieBreaker
+	}
+	return tieBreaker(req.TieBreaker)
+}
+
+// Order of two tied processes given with their positions in the request:
+// negative if a goes first, positive if b does
+func (tb tieBreaker) compare(a Process, ai int, b Process, bi int) int {
+	for _, key := range tb {
+		c := 0
+		switch key {
+		case "arrival":
+			c = a.ArrivalTime - b.ArrivalTime
+		case "id":
+			c = compareIDs(a.ID, b.ID)
+		case "input":
+			c = ai - bi
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return ai - bi
+}
+
+// Whether procs[a] goes before procs[b] when the algorithm ties them
+func (tb tieBreaker) before(procs []Process, a, b int) bool {
+	return tb.compare(procs[a], a, procs[b], b) < 0
+}
+
+// Position of each process when all of them are sorted by the tie-breaker
+// alone. Comparing ranks is much cheaper than comparing IDs, which matters
+// when a heap compares tied processes millions of times.
+func (tb tieBreaker) ranks(procs []Process) []int {
+	order := make([]int, len(procs))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(a, b int) bool {
+		return tb.before(procs, order[a], order[b])
+	})
+	rank := make([]int, len(procs))
+	for r, i := range order {
+		rank[i] = r
+	}
+	return rank
+}
+
+// Compare process IDs the way people read them: runs of digits compare as
+// numbers, everything else byte by byte
+func compareIDs(a, b string) int {
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		if isDigit(a[i]) && isDigit(b[j]) {
+			// Skip leading zeros, then the longer run is the bigger number
+			for i < len(a) && a[i] == '0' {
+				i++
+			}
+			for j < len(b) && b[j] == '0' {
+				j++
+			}
+			si, sj := i, j
+			for i < len(a) && isDigit(a[i]) {
+				i++
+			}
+			for j < len(b) && isDigit(b[j]) {
+				j++
+			}
+			if n, m := i-si, j-sj; n != m {
+				return n - m
+			}
+			for k := 0; k < i-si; k++ {
+				if a[si+k] != b[sj+k] {
+					return int(a[si+k]) - int(b[sj+k])
+				}
+			}
+			continue
+		}
+		if a[i] != b[j] {
+			return int(a[i]) - int(b[j])
+		}
+		i++
+		j++
+	}
+	return (len(a) - i) - (len(b) - j)
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
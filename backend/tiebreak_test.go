@@ -0,0 +1,44 @@
+package main
+
+import "testing"
+
+func TestTieBreakerSchedules(t *testing.T) {
+	sameBurst := `"processes": [{"id": "P10", "burstTime": 2}, {"id": "P2", "burstTime": 2}, {"id": "P3", "burstTime": 1}]}`
+	runScheduleCases(t, []scheduleCase{
+		{
+			// IDs compare numerically, so P2 goes before P10
+			name:     "default chain",
+			body:     `{"algorithm": "SJF", ` + sameBurst,
+			timeline: "P3 0-1, P2 1-3, P10 3-5",
+			times:    "P3 0/1, P2 1/3, P10 3/5",
+		},
+		{
+			name:     "input order",
+			body:     `{"algorithm": "SJF", "tieBreaker": ["input"], ` + sameBurst,
+			timeline: "P3 0-1, P10 1-3, P2 3-5",
+			times:    "P3 0/1, P10 1/3, P2 3/5",
+		},
+		{
+			// P1 ties P2 on priority and wins on ID, but that only counts
+			// when picking, so it waits for P2 to finish
+			name:     "no preemption on a tie",
+			body:     `{"algorithm": "Priority", "isPreemptive": true, "tieBreaker": ["id"], "processes": [{"id": "P2", "burstTime": 3, "priority": 1}, {"id": "P1", "arrivalTime": 1, "burstTime": 2, "priority": 1}, {"id": "P0", "arrivalTime": 1, "burstTime": 1, "priority": 2}]}`,
+			timeline: "P2 0-3, P1 3-5, P0 5-6",
+			times:    "P2 0/3, P1 2/4, P0 4/5",
+		},
+		{
+			// P2 has 2 left when P1 arrives needing 2
+			name:     "srtf tie",
+			body:     `{"algorithm": "SJF", "isPreemptive": true, "tieBreaker": ["id"], "processes": [{"id": "P2", "burstTime": 3}, {"id": "P1", "arrivalTime": 1, "burstTime": 2}]}`,
+			timeline: "P2 0-3, P1 3-5",
+			times:    "P2 0/3, P1 2/4",
+		},
+		{
+			// The step engine follows the same rule
+			name:     "engine tie",
+			body:     `{"algorithm": "SJF", "isPreemptive": true, "tieBreaker": ["id"], "processes": [{"id": "P2", "burstTime": 3, "ioBursts": [{"offset": 2, "duration": 0}]}, {"id": "P1", "arrivalTime": 1, "burstTime": 2}]}`,
+			timeline: "P2 0-3, P1 3-5",
+			times:    "P2 0/3, P1 2/4",
+		},
+	})
+}
@@ -0,0 +1,389 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Time values in requests and responses are decimals in the request's
+// TimeUnit with up to TimeResolution decimal places. Every simulator works
+// in whole ticks of 10^-TimeResolution units, so time values are scaled to
+// integers on the way in and back to exact decimals on the way out. No time
+// is ever held as a float, so long simulations cannot drift.
+
+// Which fields hold times is declared on the request and response types
+// with a struct tag: time:"ticks" for a value in ticks, and time:"average"
+// for an average of them or another statistic that is a float already.
+// Scaling walks the JSON tree alongside the Go type it decodes into or was
+// encoded from, so a key is only scaled where the type says it is a time.
+
+// A JSON key of a struct type: the Go type under it and its time tag
+type jsonField struct {
+	typ  reflect.Type
+	time string // "ticks", "average" or ""
+}
+
+var jsonFieldCache sync.Map // reflect.Type to map[string]jsonField
+
+// The JSON keys of a struct type, including those of embedded structs
+func jsonFields(t reflect.Type) map[string]jsonField {
+	if cached, ok := jsonFieldCache.Load(t); ok {
+		return cached.(map[string]jsonField)
+	}
+	fields := make(map[string]jsonField)
+	var embedded []reflect.StructField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		switch {
+		case name == "-" || !f.IsExported() && !f.Anonymous:
+		case f.Anonymous && name == "":
+			embedded = append(embedded, f)
+		default:
+			if name == "" {
+				name = f.Name
+			}
+			fields[name] = jsonField{typ: f.Type, time: f.Tag.Get("time")}
+		}
+	}
+	// Fields of the outer struct hide those it embeds
+	for _, f := range embedded {
+		for name, field := range jsonFields(indirect(f.Type)) {
+			if _, ok := fields[name]; !ok {
+				fields[name] = field
+			}
+		}
+	}
+	jsonFieldCache.Store(t, fields)
+	return fields
+}
+
+// t with any pointers removed
+func indirect(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
+
+// Finest resolution accepted: nanosecond steps when the unit is seconds
+const maxTimeResolution = 9
+
+// ExactAverages holds the average times as exact fractions in the time
+// unit, such as "31/3", since a float cannot hold most of them exactly
+type ExactAverages struct {
+	WaitingTime    string `json:"waitingTime"`
+	TurnaroundTime string `json:"turnaroundTime"`
+	ResponseTime   string `json:"responseTime"`
+}
+
+func isValidTimeUnit(unit string) bool {
+	switch unit {
+	case "", "ns", "us", "ms", "s":
+		return true
+	}
+	return false
+}
+
+// Parse a request body into a JSON tree, keeping numbers exact. Returns
+// false if the body is not a JSON object.
+func parseRequest(body []byte) (map[string]interface{}, bool) {
+	var tree interface{}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&tree); err != nil {
+		return nil, false
+	}
+	top, ok := tree.(map[string]interface{})
+	return top, ok
+}
+
+// Decode a parsed request into req, scaling every time value to ticks.
+// Returns the number of ticks per time unit, or the fields that could not
+// be decoded. The tree is left scaled.
+func decodeRequest(top map[string]interface{}, req interface{}) (int64, []FieldError) {
+	scale, errs := timeScale(top)
+	if len(errs) > 0 {
+		return 0, errs
+	}
+	if errs := scaleIn(top, reflect.TypeOf(req), scale, ""); len(errs) > 0 {
+		return 0, errs
+	}
+	scaled, err := json.Marshal(top)
+	if err != nil {
+		return 0, []FieldError{fieldError("", codeInvalidType, "Invalid request format")}
+	}
+	if err := json.Unmarshal(scaled, req); err != nil {
+		return 0, decodeError(err)
+	}
+	return scale, nil
+}
+
+// Ticks per time unit for a parsed request's TimeResolution
+func timeScale(top map[string]interface{}) (int64, []FieldError) {
+	resolution := 0
+	if v, ok := top["timeResolution"]; ok {
+		n, _ := v.(json.Number)
+		r, err := n.Int64()
+		if err != nil || r < 0 || r > maxTimeResolution {
+			return 0, []FieldError{fieldError("timeResolution", codeOutOfRange,
+				fmt.Sprintf("Time resolution must be a whole number from 0 to %d", maxTimeResolution))}
+		}
+		resolution = int(r)
+	}
+	return pow10(resolution), nil
+}
+
+// The field error for a failed json.Unmarshal of a request
+func decodeError(err error) []FieldError {
+	var te *json.UnmarshalTypeError
+	if errors.As(err, &te) {
+		field := fieldPath(te.Field)
+		return []FieldError{fieldError(field, codeInvalidType,
+			fmt.Sprintf("%s must be %s, not %s", field, typeName(te.Type.Kind()), te.Value))}
+	}
+	return []FieldError{fieldError("", codeInvalidType, "Invalid request format")}
+}
+
+// Turn a dotted path from encoding/json, such as "processes.2.burstTime",
+// into the "processes[2].burstTime" form used in field errors
+func fieldPath(dotted string) string {
+	var b strings.Builder
+	for i, part := range strings.Split(dotted, ".") {
+		if _, err := strconv.Atoi(part); err == nil {
+			b.WriteString("[" + part + "]")
+			continue
+		}
+		if i > 0 {
+			b.WriteByte('.')
+		}
+		b.WriteString(part)
+	}
+	return b.String()
+}
+
+// How a Go kind is called in JSON
+func typeName(kind reflect.Kind) string {
+	switch kind {
+	case reflect.Int, reflect.Int64, reflect.Float64:
+		return "a number"
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "true or false"
+	case reflect.Slice:
+		return "a list"
+	}
+	return "an object"
+}
+
+// Replace every time value in a decoded JSON tree of type t with its value
+// in ticks. path is where v sits in the request, for error messages.
+func scaleIn(v interface{}, t reflect.Type, scale int64, path string) []FieldError {
+	var errs []FieldError
+	t = indirect(t)
+	switch v := v.(type) {
+	case map[string]interface{}:
+		// Visit keys in order so errors come out the same way every time
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			field := key
+			if path != "" {
+				field = path + "." + key
+			}
+			var f jsonField
+			switch t.Kind() {
+			case reflect.Struct:
+				var ok bool
+				if f, ok = jsonFields(t)[key]; !ok {
+					continue // Unknown keys are ignored when decoding too
+				}
+			case reflect.Map:
+				f.typ = t.Elem()
+			default:
+				continue
+			}
+			if f.time != "ticks" {
+				errs = append(errs, scaleIn(v[key], f.typ, scale, field)...)
+				continue
+			}
+			switch child := v[key].(type) {
+			case json.Number:
+				n, err := scaleNumber(child, scale, key, field)
+				if err != nil {
+					errs = append(errs, *err)
+					continue
+				}
+				v[key] = n
+			case []interface{}:
+				// A list of time values, such as histogram edges
+				for i, item := range child {
+					if number, ok := item.(json.Number); ok {
+						n, err := scaleNumber(number, scale, key, fmt.Sprintf("%s[%d]", field, i))
+						if err != nil {
+							errs = append(errs, *err)
+							continue
+						}
+						child[i] = n
+					}
+				}
+			}
+		}
+	case []interface{}:
+		if t.Kind() != reflect.Slice && t.Kind() != reflect.Array {
+			break
+		}
+		for i, child := range v {
+			errs = append(errs, scaleIn(child, t.Elem(), scale, fmt.Sprintf("%s[%d]", path, i))...)
+		}
+	}
+	return errs
+}
+
+// One time value in ticks, or what is wrong with it
+func scaleNumber(n json.Number, scale int64, key, field string) (json.Number, *FieldError) {
+	r, ok := new(big.Rat).SetString(string(n))
+	if !ok {
+		err := fieldError(field, codeInvalidType, fmt.Sprintf("%s must be a number", key))
+		return "", &err
+	}
+	r.Mul(r, new(big.Rat).SetInt64(scale))
+	if !r.IsInt() {
+		err := fieldError(field, codeTooPrecise, fmt.Sprintf("%s %s is finer than the time resolution", key, n))
+		return "", &err
+	}
+	return json.Number(r.Num().String()), nil
+}
+
+// Response body with every time value converted from ticks back to the
+// time unit. With whole-unit resolution the response goes out unchanged.
+func encodeResponse(response interface{}, resolution int) (interface{}, error) {
+	if resolution == 0 {
+		return response, nil
+	}
+	body, err := json.Marshal(response)
+	if err != nil {
+		return nil, err
+	}
+	var tree interface{}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&tree); err != nil {
+		return nil, err
+	}
+	scaleOut(tree, reflect.TypeOf(response), resolution)
+	return tree, nil
+}
+
+// Convert every time value in a decoded JSON tree of type t from ticks to
+// the time unit. Tick counts come out exact; averages, which are floats
+// already, get six more decimal places than a tick.
+func scaleOut(v interface{}, t reflect.Type, resolution int) {
+	t = indirect(t)
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			var f jsonField
+			switch t.Kind() {
+			case reflect.Struct:
+				f = jsonFields(t)[key]
+			case reflect.Map:
+				f.typ = t.Elem()
+			default:
+				continue
+			}
+			if f.time == "" {
+				if f.typ != nil {
+					scaleOut(child, f.typ, resolution)
+				}
+				continue
+			}
+			digits := resolution
+			if f.time == "average" {
+				digits += 6
+			}
+			switch child := child.(type) {
+			case json.Number:
+				v[key] = unscaleNumber(child, resolution, digits)
+			case []interface{}:
+				for i, item := range child {
+					if number, ok := item.(json.Number); ok {
+						child[i] = unscaleNumber(number, resolution, digits)
+					}
+				}
+			}
+		}
+	case []interface{}:
+		if t.Kind() != reflect.Slice && t.Kind() != reflect.Array {
+			break
+		}
+		for _, child := range v {
+			scaleOut(child, t.Elem(), resolution)
+		}
+	}
+}
+
+// One value in ticks as a decimal in the time unit
+func unscaleNumber(n json.Number, resolution, digits int) json.Number {
+	r, ok := new(big.Rat).SetString(string(n))
+	if !ok {
+		return n
+	}
+	r.Quo(r, new(big.Rat).SetInt64(pow10(resolution)))
+	return json.Number(decimalString(r, digits))
+}
+
+// r rounded to the given number of decimal places, without trailing zeros
+func decimalString(r *big.Rat, digits int) string {
+	s := r.FloatString(digits)
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+	return s
+}
+
+// Average waiting, turnaround and response times as exact fractions of the
+// time unit, skipping abandoned jobs like withAverages does
+func exactAverages(processes []Process, scale int64) *ExactAverages {
+	var waiting, turnaround, response, counted int64
+	for _, p := range processes {
+		if p.Abandoned {
+			continue
+		}
+		waiting += int64(p.WaitingTime)
+		turnaround += int64(p.TurnaroundTime)
+		response += int64(p.ResponseTime)
+		counted++
+	}
+	if counted == 0 {
+		return nil
+	}
+	average := func(total int64) string {
+		return new(big.Rat).SetFrac64(total, counted*scale).RatString()
+	}
+	return &ExactAverages{
+		WaitingTime:    average(waiting),
+		TurnaroundTime: average(turnaround),
+		ResponseTime:   average(response),
+	}
+}
+
+func pow10(n int) int64 {
+	p := int64(1)
+	for i := 0; i < n; i++ {
+		p *= 10
+	}
+	return p
+}
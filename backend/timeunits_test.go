@@ -0,0 +1,348 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Request and response types of every endpoint. Scaling follows the time
+// tags on everything reachable from them.
+var timeTaggedRoots = []interface{}{
+	SimulationRequest{}, SimulationResponse{},
+	CompareRequest{}, CompareResponse{},
+	SweepRequest{}, SweepResponse{},
+	ExperimentRequest{}, ExperimentResponse{},
+	GenerateRequest{}, GenerateResponse{},
+	AdversaryRequest{}, AdversaryResponse{},
+	SessionResponse{},
+}
+
+// Numeric fields that do not hold a time in ticks. A new numeric field has
+// to be tagged time:"ticks" or time:"average", or listed here, before
+// TestTimeTagsComplete passes.
+var untimedFields = map[string]bool{
+	// Counts, priorities, weights and other plain numbers
+	"Process.Priority": true, "Process.Nice": true, "Process.RTPriority": true, "Process.Nodes": true,
+	"SimulationRequest.KernelThreads": true, "SimulationRequest.Nodes": true,
+	"SimulationRequest.TimeResolution": true, "SimulationResponse.TimeResolution": true,
+	"ShareGroup.Shares": true, "ShareUser.Shares": true,
+	"SelfishRRConfig.NewRate": true, "SelfishRRConfig.AcceptedRate": true,
+	"Metrics.ContextSwitches": true, "Metrics.Preemptions": true,
+	"Distribution.Count": true, "HistogramBucket.Count": true,
+	"QueueStats.MaxReadyQueue": true, "StatePoint.Ready": true, "StatePoint.Running": true, "StatePoint.Blocked": true,
+	"CgroupStats.ThrottleCount": true, "BatchReport.Nodes": true, "NodeSegment.Node": true,
+	"RankEntry.Rank": true, "ExperimentRequest.Replications": true, "ExperimentResponse.Replications": true,
+	"ExperimentResponse.Seed": true, "GenerateRequest.Count": true, "GenerateRequest.Seed": true,
+	"GenerateRequest.TimeResolution": true, "GenerateResponse.Seed": true, "GenerateResponse.TimeResolution": true,
+	"GeneratedProcess.Priority": true, "AdversaryRequest.Count": true, "AdversaryRequest.MaxPriority": true,
+	"AdversaryRequest.Restarts": true, "AdversaryRequest.Iterations": true, "AdversaryRequest.Seed": true,
+	"AdversaryResponse.Seed": true, "AdversaryResponse.Evaluations": true,
+	"SessionResponse.Index": true, "SessionResponse.StepCount": true,
+
+	// Ratios, rates and shares, which do not change with the time unit's
+	// resolution
+	"Process.BoundedSlowdown": true, "Process.NormalizedTurnaround": true,
+	"Metrics.CPUUtilization": true, "Metrics.Throughput": true, "Metrics.AverageNormalizedTurnaround": true,
+	"Distribution.CV": true, "Fairness.JainIndex": true, "Fairness.ShareError": true,
+	"Fairness.MaxSlowdown": true, "Fairness.MeanSlowdown": true,
+	"QueueStats.AverageReadyQueue": true, "QueueStats.AverageRunning": true, "QueueStats.AverageBlocked": true,
+	"LittlesLaw.ArrivalRate": true, "LittlesLaw.Predicted": true, "LittlesLaw.Measured": true, "LittlesLaw.RelativeError": true,
+	"ShareReport.TargetShare": true, "ShareReport.AchievedShare": true,
+	"MixedCriticalityReport.VirtualDeadlineFactor": true, "BatchReport.Utilization": true,
+	"BatchReport.AverageBoundedSlowdown": true, "BatchReport.MaxBoundedSlowdown": true,
+	"ExperimentResponse.Confidence": true, "PairedComparison.TStatistic": true, "PairedComparison.PValue": true,
+
+	// Already in the time unit: distribution parameters, sweep values and
+	// metrics that are converted where they are computed
+	"DistributionSpec.Rate": true, "DistributionSpec.Mean": true, "DistributionSpec.Min": true, "DistributionSpec.Max": true,
+	"DistributionSpec.Shape": true, "DistributionSpec.Scale": true, "DistributionSpec.Mu": true, "DistributionSpec.Sigma": true,
+	"DistributionSpec.Rates": true, "DistributionSpec.SwitchRates": true, "DistributionSpec.Weights": true, "DistributionSpec.Values": true,
+	"SweepRequest.Values": true, "SweepRequest.Start": true, "SweepRequest.Stop": true, "SweepRequest.Step": true,
+	"SweepResponse.Values": true, "MetricSeries.Values": true, "SweepOptimum.Value": true, "SweepOptimum.Metric": true,
+	"RankEntry.Value": true, "MetricEstimate.SampleMean": true, "MetricEstimate.SampleStdDev": true, "MetricEstimate.HalfWidth": true,
+	"MetricEstimate.Low": true, "MetricEstimate.High": true,
+	"PairedComparison.MeanDifference": true, "PairedComparison.Low": true, "PairedComparison.High": true,
+	"PairedComparison.Differences": true, "AdversaryResponse.RestartScores": true,
+	"AdversaryResponse.Score": true, "AdversaryResponse.TargetValue": true, "AdversaryResponse.BaselineValue": true,
+}
+
+// taggedField is one JSON field of a struct reachable from timeTaggedRoots
+type taggedField struct {
+	name   string // "Type.Field"
+	owner  reflect.Type
+	key    string
+	typ    reflect.Type
+	time   string
+	number bool // A number or a list of them
+}
+
+// Every field of every struct reachable from timeTaggedRoots
+func reachableFields() []taggedField {
+	var fields []taggedField
+	seen := make(map[reflect.Type]bool)
+	var walk func(t reflect.Type)
+	walk = func(t reflect.Type) {
+		for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Map {
+			t = t.Elem()
+		}
+		if t.Kind() != reflect.Struct || seen[t] {
+			return
+		}
+		seen[t] = true
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			key, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if key == "-" || !f.IsExported() {
+				continue
+			}
+			if !f.Anonymous || key != "" {
+				base := f.Type
+				for base.Kind() == reflect.Pointer || base.Kind() == reflect.Slice {
+					base = base.Elem()
+				}
+				number := base == reflect.TypeOf(json.Number(""))
+				switch base.Kind() {
+				case reflect.Int, reflect.Int64, reflect.Float64:
+					number = true
+				}
+				fields = append(fields, taggedField{t.Name() + "." + f.Name, t, key, f.Type, f.Tag.Get("time"), number})
+			}
+			walk(f.Type)
+		}
+	}
+	for _, root := range timeTaggedRoots {
+		walk(reflect.TypeOf(root))
+	}
+	return fields
+}
+
+// Every numeric field says whether it is a time, so one added without a
+// tag cannot go out in ticks unnoticed
+func TestTimeTagsComplete(t *testing.T) {
+	listed := make(map[string]bool)
+	for _, f := range reachableFields() {
+		switch {
+		case f.time != "" && f.time != "ticks" && f.time != "average":
+			t.Errorf("%s: unknown time tag %q", f.name, f.time)
+		case f.time != "" && !f.number:
+			t.Errorf("%s: time tag on a field that is not a number", f.name)
+		case f.time != "" && untimedFields[f.name]:
+			t.Errorf("%s: tagged as a time and listed as untimed", f.name)
+		case f.number && f.time == "" && !untimedFields[f.name]:
+			t.Errorf("%s: numeric field with no time tag; tag it or list it in untimedFields", f.name)
+		}
+		listed[f.name] = true
+	}
+	for name := range untimedFields {
+		if !listed[name] {
+			t.Errorf("%s is listed in untimedFields but is not a field", name)
+		}
+	}
+}
+
+// Every time field scales in to exact ticks and back out to the value sent
+func TestTimeFieldsRoundTrip(t *testing.T) {
+	for _, f := range reachableFields() {
+		if f.time != "ticks" {
+			continue
+		}
+		value := interface{}(json.Number("12.34"))
+		if f.typ.Kind() == reflect.Slice {
+			value = []interface{}{json.Number("12.34")}
+		}
+		tree := map[string]interface{}{f.key: value}
+		number := func() interface{} {
+			if list, ok := tree[f.key].([]interface{}); ok {
+				return list[0]
+			}
+			return tree[f.key]
+		}
+		if errs := scaleIn(tree, f.owner, 100, ""); len(errs) > 0 {
+			t.Fatalf("%s: scaleIn: %v", f.name, errs)
+		}
+		if got := number(); got != json.Number("1234") {
+			t.Errorf("%s: scaled to %v, want 1234", f.name, got)
+		}
+		scaleOut(tree, f.owner, 2)
+		if got := number(); got != json.Number("12.34") {
+			t.Errorf("%s: came back as %v, want 12.34", f.name, got)
+		}
+	}
+}
+
+// Averages are in ticks on the way out and keep six more decimal places
+// than a tick
+func TestAverageFieldsScaleOut(t *testing.T) {
+	for _, f := range reachableFields() {
+		if f.time != "average" {
+			continue
+		}
+		tree := map[string]interface{}{f.key: json.Number("1234.5678912345")}
+		scaleOut(tree, f.owner, 2)
+		if tree[f.key] != json.Number("12.34567891") {
+			t.Errorf("%s: came back as %v, want 12.34567891", f.name, tree[f.key])
+		}
+	}
+}
+
+// A key is scaled only where the type says it holds a time: a process's
+// I/O offset is, a distribution's min is not, and neither is a key the
+// type does not have
+func TestScaleFollowsTypes(t *testing.T) {
+	request := map[string]interface{}{
+		"timeQuantum": json.Number("0.5"),
+		"processes": []interface{}{map[string]interface{}{
+			"priority": json.Number("3"),
+			"ioBursts": []interface{}{map[string]interface{}{"offset": json.Number("1.25")}},
+			"spawns":   []interface{}{map[string]interface{}{"child": map[string]interface{}{"burstTime": json.Number("2")}}},
+			"time":     json.Number("1.5"),
+		}},
+		"histograms": map[string]interface{}{"waitingTime": map[string]interface{}{"edges": []interface{}{json.Number("0.5")}}},
+	}
+	if errs := scaleIn(request, reflect.TypeOf(&SimulationRequest{}), 100, ""); len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	process := request["processes"].([]interface{})[0].(map[string]interface{})
+	got := []interface{}{
+		request["timeQuantum"],
+		process["priority"],
+		process["ioBursts"].([]interface{})[0].(map[string]interface{})["offset"],
+		process["spawns"].([]interface{})[0].(map[string]interface{})["child"].(map[string]interface{})["burstTime"],
+		process["time"],
+		request["histograms"].(map[string]interface{})["waitingTime"].(map[string]interface{})["edges"].([]interface{})[0],
+	}
+	want := []interface{}{json.Number("50"), json.Number("3"), json.Number("125"), json.Number("200"), json.Number("1.5"), json.Number("50")}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("scaled to %v, want %v", got, want)
+	}
+
+	generate := map[string]interface{}{"arrivals": map[string]interface{}{"min": json.Number("0.5")}}
+	if errs := scaleIn(generate, reflect.TypeOf(&GenerateRequest{}), 100, ""); len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	if min := generate["arrivals"].(map[string]interface{})["min"]; min != json.Number("0.5") {
+		t.Errorf("distribution min scaled to %v", min)
+	}
+}
+
+// Run a request body the way POST /simulate does and return the response
+// as sent, as a JSON tree
+func simulateBody(t *testing.T, body string) interface{} {
+	t.Helper()
+	raw, ok := parseRequest([]byte(body))
+	if !ok {
+		t.Fatalf("bad request body %s", body)
+	}
+	var req SimulationRequest
+	scale, errs := decodeRequest(raw, &req)
+	if len(errs) == 0 {
+		errs = validateRequest(req, raw)
+	}
+	if len(errs) > 0 {
+		t.Fatalf("request rejected: %v", errs)
+	}
+	response, ok := simulate(req, scale)
+	if !ok {
+		t.Fatalf("unknown algorithm in %s", body)
+	}
+	encoded, err := encodeResponse(response, req.TimeResolution)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, _ := json.Marshal(encoded)
+	tree, _ := parseRequest(out)
+	return tree
+}
+
+// Compare two JSON trees, with numbers compared by value and floats allowed
+// a small relative error. Returns the path of the first difference.
+func sameTree(a, b interface{}, path string) string {
+	switch a := a.(type) {
+	case map[string]interface{}:
+		bm, ok := b.(map[string]interface{})
+		if !ok || len(a) != len(bm) {
+			return path
+		}
+		for key, child := range a {
+			if diff := sameTree(child, bm[key], path+"."+key); diff != "" {
+				return diff
+			}
+		}
+	case []interface{}:
+		bl, ok := b.([]interface{})
+		if !ok || len(a) != len(bl) {
+			return path
+		}
+		for i := range a {
+			if diff := sameTree(a[i], bl[i], fmt.Sprintf("%s[%d]", path, i)); diff != "" {
+				return diff
+			}
+		}
+	case json.Number:
+		bn, ok := b.(json.Number)
+		if !ok {
+			return path
+		}
+		x, _ := new(big.Rat).SetString(string(a))
+		y, _ := new(big.Rat).SetString(string(bn))
+		if x.Cmp(y) == 0 {
+			return ""
+		}
+		xf, _ := x.Float64()
+		yf, _ := y.Float64()
+		if math.Abs(xf-yf) > 1e-6*math.Max(1, math.Abs(xf)) {
+			return path
+		}
+	default:
+		if a != b {
+			return path
+		}
+	}
+	return ""
+}
+
+// The same workload gives the same response at any time resolution, so
+// every time value in a response is tagged as one
+func TestResponseIndependentOfResolution(t *testing.T) {
+	workloads := []string{
+		`"algorithm":"FCFS","processes":[{"id":"P1","arrivalTime":0,"burstTime":5},{"id":"P2","arrivalTime":1,"burstTime":3}]`,
+		`"algorithm":"SJF","isPreemptive":true,"processes":[{"id":"P1","arrivalTime":0,"burstTime":6},{"id":"P2","arrivalTime":1,"burstTime":2},{"id":"P3","arrivalTime":2,"burstTime":4}]`,
+		`"algorithm":"RR","timeQuantum":2,"histograms":{"waitingTime":{"edges":[0,2,4,8]},"turnaroundTime":{"width":2}},"processes":[{"id":"P1","arrivalTime":0,"burstTime":5},{"id":"P2","arrivalTime":1,"burstTime":3}]`,
+		`"algorithm":"Priority","processes":[{"id":"P1","arrivalTime":0,"burstTime":4,"priority":2},{"id":"P2","arrivalTime":1,"burstTime":3,"priority":1}]`,
+		`"algorithm":"Linux","processes":[{"id":"P1","arrivalTime":0,"burstTime":4,"ioBursts":[{"offset":1,"duration":2}]},{"id":"P2","arrivalTime":1,"burstTime":3,"spawns":[{"offset":1,"child":{"id":"C","burstTime":2}}]}]`,
+		`"algorithm":"RM","server":{"policy":"Sporadic","budget":1,"period":5},"processes":[{"id":"T1","arrivalTime":0,"burstTime":1,"period":4},{"id":"T2","arrivalTime":0,"burstTime":2,"period":6},{"id":"A","arrivalTime":3,"burstTime":2}]`,
+		`"algorithm":"EDF","server":{"policy":"CBS","budget":2,"period":6},"processes":[{"id":"T1","arrivalTime":0,"burstTime":2,"period":5},{"id":"A","arrivalTime":1,"burstTime":3}]`,
+		`"algorithm":"EASY","nodes":4,"processes":[{"id":"J1","arrivalTime":0,"burstTime":5,"walltime":6,"nodes":2},{"id":"J2","arrivalTime":1,"burstTime":3,"walltime":4,"nodes":4},{"id":"J3","arrivalTime":2,"burstTime":2,"walltime":2,"nodes":1}]`,
+	}
+	for _, w := range workloads {
+		whole := simulateBody(t, "{"+w+"}")
+		fine := simulateBody(t, `{"timeResolution":3,`+w+"}")
+		if diff := sameTree(whole, fine, ""); diff != "" && !strings.HasSuffix(diff, ".timeResolution") {
+			t.Errorf("%s: responses differ at %s", w, diff)
+		}
+	}
+}
+
+// Simulators that step one tick at a time refuse work that would take too
+// many ticks, rather than running for minutes at a fine resolution
+func TestSteppedTicksLimit(t *testing.T) {
+	body := `{"algorithm":"LJF","isPreemptive":true,"timeUnit":"s","timeResolution":9,
+		"processes":[{"id":"P1","arrivalTime":0,"burstTime":5},{"id":"P2","arrivalTime":0,"burstTime":3}]}`
+	raw, _ := parseRequest([]byte(body))
+	var req SimulationRequest
+	if _, errs := decodeRequest(raw, &req); len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	errs := validateRequest(req, raw)
+	if len(errs) != 1 || errs[0].Field != "timeResolution" || errs[0].Code != codeOutOfRange {
+		t.Fatalf("got %v, want one out_of_range error on timeResolution", errs)
+	}
+
+	// Non-preemptive LJF is event-driven, so the same workload runs
+	simulateBody(t, strings.Replace(body, `"isPreemptive":true`, `"isPreemptive":false`, 1))
+}
@@ -0,0 +1,333 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// FieldError is one problem with a request, pointing at the offending field
+// with a JSON path such as "processes[2].burstTime" so the frontend can
+// highlight the exact row
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Machine-readable error codes
+const (
+	codeRequired    = "required"      // Missing or empty
+	codeNegative    = "negative"      // Must be zero or more
+	codeNotPositive = "not_positive"  // Must be more than zero
+	codeOutOfRange  = "out_of_range"  // Outside the allowed range
+	codeDuplicate   = "duplicate"     // Already used by another entry
+	codeUnknown     = "unknown_value" // Not one of the accepted values
+	codeUnsupported = "unsupported"   // Valid on its own, but not in this combination
+	codeTooPrecise  = "too_precise"   // More decimal places than the time resolution
+	codeInvalidType = "invalid_type"  // Wrong JSON type
+)
+
+func fieldError(field, code, message string) FieldError {
+	return FieldError{Field: field, Code: code, Message: message}
+}
+
+// Reply 422 with the list of problems
+func rejectRequest(c *gin.Context, errs []FieldError) {
+	c.JSON(http.StatusUnprocessableEntity, gin.H{
+		"error":  errs[0].Message,
+		"errors": errs,
+	})
+}
+
+func isKnownAlgorithm(algorithm string) bool {
+	switch algorithm {
+	case "FCFS", "SJF", "LJF", "RR", "DynamicRR", "SelfishRR", "VRR",
+		"Priority", "PriorityRR", "FairShare", "Linux",
+		"RM", "EDF", "AMC", "EDF-VD",
+		"BatchFCFS", "EASY", "Conservative":
+		return true
+	}
+	return false
+}
+
+func isPriorityAlgorithm(algorithm string) bool {
+	return algorithm == "Priority" || algorithm == "PriorityRR"
+}
+
+func isRealtimeAlgorithm(algorithm string) bool {
+	switch algorithm {
+	case "RM", "EDF", "AMC", "EDF-VD":
+		return true
+	}
+	return false
+}
+
+func isBatchAlgorithm(algorithm string) bool {
+	switch algorithm {
+	case "BatchFCFS", "EASY", "Conservative":
+		return true
+	}
+	return false
+}
+
+// Check every field of a decoded request. raw is the request as sent, used
+// to tell a missing value from a zero one.
+func validateRequest(req SimulationRequest, raw map[string]interface{}) []FieldError {
+	var errs []FieldError
+	add := func(field, code, message string) {
+		errs = append(errs, fieldError(field, code, message))
+	}
+	nonNegative := func(field, name string, v int) {
+		if v < 0 {
+			add(field, codeNegative, name+" cannot be negative")
+		}
+	}
+
+	switch {
+	case req.Algorithm == "":
+		add("algorithm", codeRequired, "Algorithm is required")
+	case !isKnownAlgorithm(req.Algorithm):
+		add("algorithm", codeUnknown, "Unknown algorithm")
+	case needsEngine(req) && !engineSupports(req.Algorithm):
+		add("algorithm", codeUnsupported, "This algorithm cannot run workloads that fork, do I/O, have threads or use cgroups")
+	}
+
+	// Options
+	nonNegative("timeQuantum", "Time quantum", req.TimeQuantum)
+	nonNegative("kernelThreads", "Kernel threads", req.KernelThreads)
+	nonNegative("rtRuntime", "RT runtime", req.RTRuntime)
+	nonNegative("rtPeriod", "RT period", req.RTPeriod)
+	nonNegative("horizon", "Horizon", req.Horizon)
+	if req.Horizon > maxRequestedHorizon*int(pow10(req.TimeResolution)) {
+		add("horizon", codeOutOfRange, fmt.Sprintf("Horizon cannot exceed %d time units", maxRequestedHorizon))
+	}
+	nonNegative("slowdownBound", "Slowdown bound", req.SlowdownBound)
+	nonNegative("starvationThreshold", "Starvation threshold", req.StarvationThreshold)
+	if !isValidInheritPolicy(req.InheritPolicy) {
+		add("inheritPolicy", codeUnknown, "Unknown inherit policy")
+	}
+	if !isValidThreadingModel(req.ThreadingModel) {
+		add("threadingModel", codeUnknown, "Unknown threading model")
+	}
+	if !isValidPriorityOrder(req.PriorityOrder) {
+		add("priorityOrder", codeUnknown, "Unknown priority order")
+	}
+	if !isValidTimeUnit(req.TimeUnit) {
+		add("timeUnit", codeUnknown, "Unknown time unit")
+	}
+	errs = append(errs, validateTieBreaker(req.TieBreaker)...)
+	errs = append(errs, validateRR(req)...)
+	errs = append(errs, validateShares(req)...)
+	errs = append(errs, validateHistograms(req.Histograms)...)
+
+	// Processes
+	if len(req.Processes) == 0 {
+		add("processes", codeRequired, "No processes provided")
+		return errs
+	}
+	var rawProcs []interface{}
+	if raw != nil {
+		rawProcs, _ = raw["processes"].([]interface{})
+	}
+	threaded := hasThreads(req.Processes)
+	for i, p := range req.Processes {
+		path := fmt.Sprintf("processes[%d]", i)
+		nonNegative(path+".arrivalTime", "Arrival time", p.ArrivalTime)
+
+		// Priority algorithms need every priority spelled out, since 0 is a
+		// real priority and cannot stand in for a missing one
+		if isPriorityAlgorithm(req.Algorithm) && i < len(rawProcs) {
+			if rp, ok := rawProcs[i].(map[string]interface{}); ok {
+				if _, ok := rp["priority"]; !ok {
+					add(path+".priority", codeRequired, "Priority is required for priority scheduling")
+				}
+			}
+		}
+		if threaded && len(p.Spawns) > 0 {
+			add(path+".spawns", codeUnsupported, "Spawns are not supported together with threads")
+		}
+		errs = append(errs, validateProcess(path, p)...)
+	}
+	errs = append(errs, validateEntityIDs(req.Processes)...)
+
+	errs = append(errs, validateCgroups(req.Cgroups, req.Processes)...)
+	if isRealtimeAlgorithm(req.Algorithm) {
+		errs = append(errs, validateServer(req.Algorithm, req.Server)...)
+		errs = append(errs, validateCriticality(req)...)
+	}
+	if isBatchAlgorithm(req.Algorithm) {
+		errs = append(errs, validateBatch(req)...)
+	}
+
+	// Simulators that step one tick at a time take 10^timeResolution times
+	// longer at each finer resolution, so their length is capped in ticks
+	if len(errs) == 0 && steppedTicks(req) > maxSteppedTicks {
+		add("timeResolution", codeOutOfRange, fmt.Sprintf(
+			"This workload needs more than %d simulation steps at this resolution; use a coarser time resolution or less work", maxSteppedTicks))
+	}
+	return errs
+}
+
+// Check one process, and the children it forks, under the given path
+func validateProcess(path string, p Process) []FieldError {
+	var errs []FieldError
+	add := func(field, code, message string) {
+		errs = append(errs, fieldError(path+"."+field, code, message))
+	}
+
+	if p.ID == "" {
+		add("id", codeRequired, "Process ID is required")
+	}
+	if len(p.Threads) == 0 && p.BurstTime <= 0 {
+		add("burstTime", codeNotPositive, "Burst time must be positive")
+	}
+	if p.Nice < -20 || p.Nice > 19 {
+		add("nice", codeOutOfRange, "Nice value must be between -20 and 19")
+	}
+	if !isValidPolicy(p.Policy) {
+		add("policy", codeUnknown, "Unknown scheduling policy")
+	} else if isRealtime(p) && (p.RTPriority < 1 || p.RTPriority > 99) {
+		add("rtPriority", codeOutOfRange, "Real-time priority must be between 1 and 99")
+	}
+	if p.Period < 0 {
+		add("period", codeNegative, "Period cannot be negative")
+	}
+	if p.Deadline < 0 {
+		add("deadline", codeNegative, "Deadline cannot be negative")
+	}
+	if p.Nodes < 0 {
+		add("nodes", codeNegative, "Nodes cannot be negative")
+	}
+	if p.Walltime < 0 {
+		add("walltime", codeNegative, "Walltime cannot be negative")
+	}
+
+	for k, io := range p.IOBursts {
+		errs = append(errs, validateIOBurst(fmt.Sprintf("%s.ioBursts[%d]", path, k), io, p.BurstTime)...)
+	}
+	for k, s := range p.Spawns {
+		sp := fmt.Sprintf("%s.spawns[%d]", path, k)
+		if s.Offset < 0 || s.Offset > p.BurstTime {
+			errs = append(errs, fieldError(sp+".offset", codeOutOfRange, "Spawn offset must be within the parent's burst"))
+		}
+		// Children without an ID are named after their parent
+		child := s.Child
+		if child.ID == "" {
+			child.ID = defaultChildID(p.ID, k)
+		}
+		errs = append(errs, validateProcess(sp+".child", child)...)
+	}
+
+	threadIDs := make(map[string]bool)
+	for k, t := range p.Threads {
+		tp := fmt.Sprintf("%s.threads[%d]", path, k)
+		switch {
+		case t.ID == "":
+			errs = append(errs, fieldError(tp+".id", codeRequired, "Thread ID is required"))
+		case threadIDs[t.ID]:
+			errs = append(errs, fieldError(tp+".id", codeDuplicate, "Thread ID is already used in this process"))
+		}
+		threadIDs[t.ID] = true
+		if t.BurstTime <= 0 {
+			errs = append(errs, fieldError(tp+".burstTime", codeNotPositive, "Burst time must be positive"))
+		}
+		if t.StartOffset < 0 {
+			errs = append(errs, fieldError(tp+".startOffset", codeNegative, "Start offset cannot be negative"))
+		}
+		for j, io := range t.IOBursts {
+			errs = append(errs, validateIOBurst(fmt.Sprintf("%s.ioBursts[%d]", tp, j), io, t.BurstTime)...)
+		}
+	}
+	return errs
+}
+
+// Check that no two entities share an ID: processes, the children they
+// fork (named after their parent by default) and threads, which run as
+// "process:thread". Top-level processes claim their IDs first, so a clash
+// is reported at the child or thread that a simulation would create.
+func validateEntityIDs(processes []Process) []FieldError {
+	var errs []FieldError
+	seen := make(map[string]string) // ID to the path of its first user
+	claim := func(id, path, message string) {
+		if first, ok := seen[id]; ok {
+			errs = append(errs, fieldError(path+".id", codeDuplicate, fmt.Sprintf(message, id, first)))
+			return
+		}
+		seen[id] = path
+	}
+
+	for i, p := range processes {
+		if p.ID != "" {
+			claim(p.ID, fmt.Sprintf("processes[%d]", i), "Process ID %s is already used by %s")
+		}
+	}
+
+	var walk func(path string, p Process)
+	walk = func(path string, p Process) {
+		for k, s := range p.Spawns {
+			sp := fmt.Sprintf("%s.spawns[%d].child", path, k)
+			child := s.Child
+			if child.ID == "" {
+				child.ID = defaultChildID(p.ID, k)
+				claim(child.ID, sp, "Default child ID %s is already used by %s; give the child its own ID")
+			} else {
+				claim(child.ID, sp, "Process ID %s is already used by %s")
+			}
+			walk(sp, child)
+		}
+		threadIDs := make(map[string]bool)
+		for k, t := range p.Threads {
+			// Thread IDs repeated within a process are reported on their own
+			if t.ID == "" || threadIDs[t.ID] {
+				continue
+			}
+			threadIDs[t.ID] = true
+			claim(p.ID+":"+t.ID, fmt.Sprintf("%s.threads[%d]", path, k), "Thread ID %s is already used by %s")
+		}
+	}
+	for i, p := range processes {
+		walk(fmt.Sprintf("processes[%d]", i), p)
+	}
+	return errs
+}
+
+// ID of the k-th child forked by a process when the spawn does not set one
+func defaultChildID(parentID string, k int) string {
+	return parentID + "." + strconv.Itoa(k+1)
+}
+
+func validateIOBurst(path string, io IOBurst, burst int) []FieldError {
+	var errs []FieldError
+	if io.Offset < 0 || io.Offset > burst {
+		errs = append(errs, fieldError(path+".offset", codeOutOfRange, "I/O offset must be within the burst"))
+	}
+	if io.Duration < 0 {
+		errs = append(errs, fieldError(path+".duration", codeNegative, "I/O duration cannot be negative"))
+	}
+	return errs
+}
+
+// Check the FairShare weights
+func validateShares(req SimulationRequest) []FieldError {
+	var errs []FieldError
+	for i, g := range req.ShareGroups {
+		switch {
+		case g.Shares < 0:
+			errs = append(errs, fieldError(fmt.Sprintf("shareGroups[%d].shares", i), codeNegative, "Shares cannot be negative"))
+		case g.Shares > maxShares:
+			errs = append(errs, fieldError(fmt.Sprintf("shareGroups[%d].shares", i), codeOutOfRange, fmt.Sprintf("Shares can be at most %d", maxShares)))
+		}
+	}
+	for i, u := range req.ShareUsers {
+		switch {
+		case u.Shares < 0:
+			errs = append(errs, fieldError(fmt.Sprintf("shareUsers[%d].shares", i), codeNegative, "Shares cannot be negative"))
+		case u.Shares > maxShares:
+			errs = append(errs, fieldError(fmt.Sprintf("shareUsers[%d].shares", i), codeOutOfRange, fmt.Sprintf("Shares can be at most %d", maxShares)))
+		}
+	}
+	return errs
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// Children and threads are entities too, so their IDs cannot clash with a
+// process's, even when the ID is the default one a child gets
+func TestEntityIDCollisions(t *testing.T) {
+	cases := []struct {
+		name      string
+		processes []Process
+		want      []string // Fields with a duplicate error
+	}{
+		{
+			name: "distinct",
+			processes: []Process{
+				{ID: "P1", BurstTime: 4, Spawns: []Spawn{{Offset: 1, Child: Process{BurstTime: 1}}}},
+				{ID: "P2", BurstTime: 4},
+			},
+		},
+		{
+			name:      "top-level duplicate",
+			processes: []Process{{ID: "P1", BurstTime: 1}, {ID: "P1", BurstTime: 1}},
+			want:      []string{"processes[1].id"},
+		},
+		{
+			name: "default child ID",
+			processes: []Process{
+				{ID: "P1", BurstTime: 4, Spawns: []Spawn{{Offset: 1, Child: Process{BurstTime: 1}}}},
+				{ID: "P1.1", BurstTime: 2},
+			},
+			want: []string{"processes[0].spawns[0].child.id"},
+		},
+		{
+			name: "grandchild default ID",
+			processes: []Process{
+				{ID: "P1", BurstTime: 4, Spawns: []Spawn{{Offset: 1, Child: Process{BurstTime: 2,
+					Spawns: []Spawn{{Offset: 1, Child: Process{BurstTime: 1}}}}}}},
+				{ID: "P1.1.1", BurstTime: 2},
+			},
+			want: []string{"processes[0].spawns[0].child.spawns[0].child.id"},
+		},
+		{
+			name: "explicit child ID",
+			processes: []Process{
+				{ID: "P1", BurstTime: 4, Spawns: []Spawn{{Offset: 1, Child: Process{ID: "P2", BurstTime: 1}}}},
+				{ID: "P2", BurstTime: 2},
+			},
+			want: []string{"processes[0].spawns[0].child.id"},
+		},
+		{
+			name: "thread entity ID",
+			processes: []Process{
+				{ID: "P", Threads: []Thread{{ID: "T", BurstTime: 2}}},
+				{ID: "P:T", BurstTime: 2},
+			},
+			want: []string{"processes[0].threads[0].id"},
+		},
+	}
+	for _, c := range cases {
+		var got []string
+		for _, e := range validateEntityIDs(c.processes) {
+			if e.Code != codeDuplicate {
+				t.Errorf("%s: unexpected %v", c.name, e)
+			}
+			got = append(got, e.Field)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: duplicates at %v, want %v", c.name, got, c.want)
+		}
+	}
+}